@@ -0,0 +1,77 @@
+// Command compression-report turns go test -bench output from the compression benchmarks into a
+// table of compress MB/s, decompress MB/s and ratio per configuration, marking the Pareto-optimal ones.
+//
+// Usage:
+//
+//	go test ./compression -run '^$' -bench . -count 5 > results.txt
+//	go run ./cmd/compression-report -format markdown results.txt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go-benchmarks/compression/report"
+)
+
+func main() {
+	format := flag.String("format", "markdown", "output format: markdown or csv")
+	paretoOnly := flag.Bool("pareto", false, "only list Pareto-optimal configurations")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: compression-report [flags] [bench-output...]\n\nReads standard input if no files are given.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(*format, *paretoOnly, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "compression-report:", err)
+		os.Exit(1)
+	}
+}
+
+func run(format string, paretoOnly bool, files []string) error {
+	write := report.WriteMarkdown
+	switch format {
+	case "markdown":
+	case "csv":
+		write = report.WriteCSV
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	var in io.Reader = os.Stdin
+	if len(files) > 0 {
+		readers := make([]io.Reader, 0, len(files))
+		for _, name := range files {
+			f, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			// Separate files with a newline in case one does not end with one
+			readers = append(readers, f, strings.NewReader("\n"))
+		}
+		in = io.MultiReader(readers...)
+	}
+
+	rows, err := report.Parse(in)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("no compression or decompression results found")
+	}
+	if paretoOnly {
+		optimal := rows[:0]
+		for _, r := range rows {
+			if r.Pareto {
+				optimal = append(optimal, r)
+			}
+		}
+		rows = optimal
+	}
+	return write(os.Stdout, rows)
+}
@@ -1,6 +1,8 @@
 package compression
 
 import (
+	"strconv"
+
 	"github.com/brianvoe/gofakeit/v7"
 )
 
@@ -11,17 +13,59 @@ const (
 	LargeSize  = 100 << 20 // 100 MB
 )
 
+// SmallPayloadSizes are RPC and message-queue sized payloads where framing overhead dominates
+var SmallPayloadSizes = []int{64, 512, 4 << 10, 32 << 10, 256 << 10}
+
 // Data types for test generation
 const (
 	RandomData = "random"
 	TextData   = "text"
 	BinaryData = "binary"
+	LogData    = "log"    // CI job output with go test results
+	CSVData    = "csv"    // tabular order records
+	SourceData = "source" // Go source files
+	NDJSONData = "ndjson" // one JSON event per line
+	VarintData = "varint" // length-prefixed protobuf-style records
 )
 
+// sizeLabel formats a byte count for benchmark names, e.g. 4KB or 10MB
+func sizeLabel(size int) string {
+	switch {
+	case size >= 1<<30 && size%(1<<30) == 0:
+		return strconv.Itoa(size>>30) + "GB"
+	case size >= 1<<20 && size%(1<<20) == 0:
+		return strconv.Itoa(size>>20) + "MB"
+	case size >= 1<<10 && size%(1<<10) == 0:
+		return strconv.Itoa(size>>10) + "KB"
+	default:
+		return strconv.Itoa(size) + "B"
+	}
+}
+
+// DataTypes lists every data type GenerateTestData understands
+var DataTypes = []string{RandomData, TextData, BinaryData, LogData, CSVData, SourceData, NDJSONData, VarintData}
+
+// DefaultSeed is the seed GenerateTestData uses, so every run benchmarks the same input
+const DefaultSeed = 42
+
+// jsonFields pins the schema of JSON chunks, as gofakeit's random JSON options are not reproducible
+var jsonFields = []gofakeit.Field{
+	{Name: "id", Function: "uuid"},
+	{Name: "name", Function: "name"},
+	{Name: "email", Function: "email"},
+	{Name: "created", Function: "date"},
+	{Name: "active", Function: "bool"},
+	{Name: "score", Function: "float64"},
+}
+
 // GenerateTestData creates sample data of specified type and size using gofakeit
 func GenerateTestData(size int, dataType string) []byte {
-	// Initialize gofakeit with a fixed seed for reproducible results
-	gofakeit.Seed(42)
+	return GenerateTestDataSeed(size, dataType, DefaultSeed)
+}
+
+// GenerateTestDataSeed creates sample data of specified type and size that depends only on its arguments
+func GenerateTestDataSeed(size int, dataType string, seed uint64) []byte {
+	faker := gofakeit.New(seed)
 
 	data := make([]byte, 0, size)
 
@@ -29,31 +73,35 @@ func GenerateTestData(size int, dataType string) []byte {
 	case RandomData:
 		// Generate random bytes until we reach the desired size
 		for len(data) < size {
-			data = append(data, byte(gofakeit.IntRange(0, 255)))
+			data = append(data, byte(faker.IntRange(0, 255)))
 		}
 	case TextData:
 		// Generate realistic text data using gofakeit
 		for len(data) < size {
 			var chunk []byte
-			switch gofakeit.IntRange(0, 3) {
+			switch faker.IntRange(0, 3) {
 			case 0:
 				// Generate a paragraph
-				chunk = []byte(gofakeit.Paragraph(1, 5, 20, " "))
+				chunk = []byte(faker.Paragraph(1, 5, 20, " "))
 			case 1:
 				// Generate a sentence
-				chunk = []byte(gofakeit.Sentence(gofakeit.IntRange(3, 10)))
+				chunk = []byte(faker.Sentence(faker.IntRange(3, 10)))
 			case 2:
 				// Generate JSON-like data
-				jsonData, _ := gofakeit.JSON(nil)
+				jsonData, _ := faker.JSON(&gofakeit.JSONOptions{
+					Type:     "array",
+					RowCount: faker.IntRange(1, 3),
+					Fields:   jsonFields,
+				})
 				chunk = jsonData
 			case 3:
 				// Generate a word
-				chunk = []byte(gofakeit.Word())
+				chunk = []byte(faker.Word())
 			}
 
 			// Add a space or newline between chunks
 			if len(data) > 0 {
-				if gofakeit.Bool() {
+				if faker.Bool() {
 					chunk = append([]byte{' '}, chunk...)
 				} else {
 					chunk = append([]byte{'\n'}, chunk...)
@@ -68,25 +116,35 @@ func GenerateTestData(size int, dataType string) []byte {
 		for len(data) < size {
 			if len(data)%1024 < 512 {
 				// Pattern-based section
-				patternLength := gofakeit.IntRange(64, 256)
+				patternLength := faker.IntRange(64, 256)
 				pattern := make([]byte, patternLength)
 				for i := range pattern {
 					pattern[i] = byte(i % 256)
 				}
 
 				// Repeat pattern until we fill a section
-				sectionSize := gofakeit.IntRange(512, 4096)
+				sectionSize := faker.IntRange(512, 4096)
 				for i := 0; i < sectionSize && len(data) < size; i++ {
 					data = append(data, pattern[i%len(pattern)])
 				}
 			} else {
 				// Random binary section
-				sectionSize := gofakeit.IntRange(512, 4096)
+				sectionSize := faker.IntRange(512, 4096)
 				for i := 0; i < sectionSize && len(data) < size; i++ {
-					data = append(data, byte(gofakeit.IntRange(0, 255)))
+					data = append(data, byte(faker.IntRange(0, 255)))
 				}
 			}
 		}
+	case LogData:
+		data = generateLogs(faker, size)
+	case CSVData:
+		data = generateCSV(faker, size)
+	case SourceData:
+		data = generateGoSource(faker, size)
+	case NDJSONData:
+		data = generateNDJSON(faker, size)
+	case VarintData:
+		data = generateVarintRecords(faker, size)
 	}
 
 	// Trim to exact size
@@ -96,3 +154,40 @@ func GenerateTestData(size int, dataType string) []byte {
 
 	return data
 }
+
+// Shape of the matches GenerateEntropyData copies, within reach of every codec
+const (
+	entropyWindow   = 32 << 10
+	entropyMinMatch = 4
+	entropyMaxMatch = 64
+	entropyMaxRun   = 32 // longest run of random literals
+)
+
+// GenerateEntropyData creates data whose bytes are random literals with probability randomness and copies otherwise
+func GenerateEntropyData(size int, randomness float64, seed uint64) []byte {
+	faker := gofakeit.New(seed)
+	randomness = min(max(randomness, 0), 1)
+
+	data := make([]byte, 0, size)
+	literals := 0
+	for len(data) < size {
+		// Emit literals whenever the data produced so far is less random than the target,
+		// and always for the first entropyMaxMatch bytes so there is something to copy
+		if len(data) < entropyMaxMatch || float64(literals) < randomness*float64(len(data)+1) {
+			n := min(faker.IntRange(1, entropyMaxRun), size-len(data))
+			for range n {
+				data = append(data, byte(faker.IntRange(0, 255)))
+			}
+			literals += n
+			continue
+		}
+		offset := faker.IntRange(1, min(len(data), entropyWindow))
+		n := min(faker.IntRange(entropyMinMatch, entropyMaxMatch), size-len(data))
+		// Copy byte by byte so matches may overlap their own output, as in LZ77
+		start := len(data) - offset
+		for i := range n {
+			data = append(data, data[start+i])
+		}
+	}
+	return data
+}
@@ -0,0 +1,190 @@
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	klauspost "github.com/klauspost/compress/zstd"
+)
+
+// Decompression bomb parameters: decoders must abort by bombOutputLimit bytes and stay under bombMemoryBudget
+const (
+	bombLargeSize    = 10 << 30 // expanded size of benchmark bombs when bombLargeEnv is set
+	bombTestSize     = 256 << 20
+	bombOutputLimit  = 16 << 20
+	bombMemoryBudget = 64 << 20
+	bombWindowLimit  = 1 << 20 // smaller than the windows zstd encoders pick for large inputs
+)
+
+// bombLargeEnv opts the benchmark in to bombLargeSize bombs, which take minutes to build
+const bombLargeEnv = "COMPRESSION_LARGE_BOMBS"
+
+// bombBenchSize is the expanded size of benchmark bombs: bombLargeSize when bombLargeEnv is set, else bombTestSize
+func bombBenchSize() int64 {
+	if os.Getenv(bombLargeEnv) != "" {
+		return bombLargeSize
+	}
+	return bombTestSize
+}
+
+// errBombLimit is returned when a decoder produced more than bombOutputLimit bytes and was stopped
+var errBombLimit = errors.New("decoded output exceeded the limit")
+
+// bombDecoder is one bounded way of decoding untrusted input
+type bombDecoder struct {
+	name   string
+	decode func(c Codec, src []byte) (int64, error)
+}
+
+// limitReaderDecoder caps any codec's streaming reader with io.LimitReader
+var limitReaderDecoder = bombDecoder{"limit-reader", decodeLimited}
+
+// bombDecoders lists the bounded decoder configurations benchmarked for each codec
+func bombDecoders(c Codec) []bombDecoder {
+	decoders := []bombDecoder{limitReaderDecoder}
+	if c.Name() == klauspostZstd.Name() {
+		decoders = append(decoders,
+			bombDecoder{"max-memory", decodeKlauspostMaxMemory},
+			bombDecoder{"max-window", decodeKlauspostMaxWindow},
+		)
+	}
+	return decoders
+}
+
+// decodeLimited reads through the codec's streaming reader and stops after bombOutputLimit bytes
+func decodeLimited(c Codec, src []byte) (int64, error) {
+	zr, err := c.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+	return copyLimited(zr)
+}
+
+// decodeKlauspostMaxMemory decodes in one shot into a preallocated buffer with WithDecoderMaxMemory capping the output
+func decodeKlauspostMaxMemory(_ Codec, src []byte) (int64, error) {
+	dec, err := klauspost.NewReader(nil, klauspost.WithDecoderMaxMemory(bombOutputLimit))
+	if err != nil {
+		return 0, err
+	}
+	defer dec.Close()
+	out, err := dec.DecodeAll(src, make([]byte, 0, bombOutputLimit))
+	return int64(len(out)), err
+}
+
+// decodeKlauspostMaxWindow streams with WithDecoderMaxWindow and a single low-memory decoder goroutine
+func decodeKlauspostMaxWindow(_ Codec, src []byte) (int64, error) {
+	dec, err := klauspost.NewReader(bytes.NewReader(src),
+		klauspost.WithDecoderMaxWindow(bombWindowLimit),
+		klauspost.WithDecoderConcurrency(1),
+		klauspost.WithDecoderLowmem(true),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer dec.Close()
+	return copyLimited(dec)
+}
+
+// copyLimited discards r until EOF or until more than bombOutputLimit bytes were read
+func copyLimited(r io.Reader) (int64, error) {
+	n, err := io.Copy(io.Discard, io.LimitReader(r, bombOutputLimit+1))
+	if err != nil {
+		return n, err
+	}
+	if n > bombOutputLimit {
+		return n, errBombLimit
+	}
+	return n, nil
+}
+
+// bomb returns size zero bytes compressed with the codec's first level
+func bomb(tb testing.TB, c Codec, size int64) []byte {
+	tb.Helper()
+	var buf bytes.Buffer
+	zw, err := c.NewWriter(&buf, c.Levels()[0])
+	if err != nil {
+		tb.Fatal(err)
+	}
+	zeros := make([]byte, 1<<20)
+	for written := int64(0); written < size; written += int64(len(zeros)) {
+		if _, err := zw.Write(zeros[:min(int64(len(zeros)), size-written)]); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestDecompressionBombLimits checks that every bounded decoder aborts on a bomb within bombMemoryBudget
+func TestDecompressionBombLimits(t *testing.T) {
+	for _, c := range Codecs() {
+		src := bomb(t, c, bombTestSize)
+		for _, d := range bombDecoders(c) {
+			t.Run(fmt.Sprintf("codec=%s/decoder=%s", c.Name(), d.name), func(t *testing.T) {
+				var n int64
+				var err error
+				stats := measureMemory(func() {
+					n, err = d.decode(c, src)
+				})
+				if err == nil {
+					t.Fatalf("decoded the whole %s bomb (%d bytes) without aborting", sizeLabel(bombTestSize), n)
+				}
+				if stats.allocated > bombMemoryBudget {
+					t.Errorf("allocated %d bytes before aborting, budget is %d", stats.allocated, bombMemoryBudget)
+				}
+				if stats.rssKnown && stats.peakRSS > bombMemoryBudget {
+					t.Errorf("RSS grew by %d bytes before aborting, budget is %d", stats.peakRSS, bombMemoryBudget)
+				}
+				t.Logf("aborted after %d bytes with %v; peak heap %d, allocated %d, peak RSS %d",
+					n, err, stats.peakHeap, stats.allocated, stats.peakRSS)
+			})
+		}
+	}
+}
+
+// BenchmarkDecompressionBomb measures how quickly each bounded decoder configuration aborts on a bomb
+func BenchmarkDecompressionBomb(b *testing.B) {
+	size := bombBenchSize()
+	for _, c := range Codecs() {
+		b.Run("codec="+c.Name(), func(b *testing.B) {
+			b.Run("size="+sizeLabel(int(size)), func(b *testing.B) {
+				src := bomb(b, c, size)
+				for _, d := range bombDecoders(c) {
+					b.Run("decoder="+d.name, func(b *testing.B) {
+						benchmarkBomb(b, c, d, src, size)
+					})
+				}
+			})
+		})
+	}
+}
+
+func benchmarkBomb(b *testing.B, c Codec, d bombDecoder, src []byte, size int64) {
+	var n int64
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		n, err = d.decode(c, src)
+		if err == nil {
+			b.Fatal("Bomb decoded without aborting")
+		}
+	}
+	b.StopTimer()
+
+	b.SetBytes(n)
+	b.ReportMetric(float64(size)/float64(len(src)), "bomb-ratio")
+	// The decoder must abort, so here it is success that is the error
+	reportMemory(b, func() error {
+		if _, err := d.decode(c, src); err == nil {
+			return errors.New("bomb decoded without aborting")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,156 @@
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"sync"
+)
+
+// Codec adapts a compression library to the shared benchmark driver
+type Codec interface {
+	// Name identifies the codec in benchmark names, e.g. "gzip-klauspost".
+	Name() string
+
+	// Levels lists the compression levels the codec is benchmarked at.
+	Levels() []int
+
+	// Compress appends the compressed form of src to dst.
+	Compress(dst, src []byte, level int) ([]byte, error)
+
+	// Decompress appends the decompressed form of src to dst.
+	Decompress(dst, src []byte) ([]byte, error)
+
+	// NewWriter returns a streaming compressor that writes to w.
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+
+	// NewReader returns a streaming decompressor that reads from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// LevelDescriber is implemented by codecs whose numeric levels map onto named library settings
+type LevelDescriber interface {
+	DescribeLevel(level int) string
+}
+
+// Resetter is implemented by codecs whose streaming writers and readers can be reused for a new stream
+type Resetter interface {
+	// ResetWriter reuses a writer returned by NewWriter to compress into w.
+	ResetWriter(zw io.WriteCloser, w io.Writer) error
+
+	// ResetReader reuses a reader returned by NewReader to decompress from r.
+	ResetReader(zr io.ReadCloser, r io.Reader) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+	skipped  = make(map[string]string)
+)
+
+// RegisterCodec makes a codec available to the benchmark driver, panicking on a duplicate name
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	name := c.Name()
+	if _, dup := codecs[name]; dup {
+		panic(fmt.Sprintf("compression: codec %q registered twice", name))
+	}
+	codecs[name] = c
+}
+
+// SkipCodec records that a codec is not available in this build, e.g. because it needs cgo
+func SkipCodec(name, reason string) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	skipped[name] = reason
+}
+
+// SkippedCodecs returns the reason each codec recorded with SkipCodec is unavailable, keyed by name.
+func SkippedCodecs() map[string]string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	return maps.Clone(skipped)
+}
+
+// LookupCodec returns the registered codec with the given name.
+func LookupCodec(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	c, ok := codecs[name]
+	return c, ok
+}
+
+// Codecs returns all registered codecs sorted by name.
+func Codecs() []Codec {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	list := make([]Codec, 0, len(codecs))
+	for _, c := range codecs {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list
+}
+
+// compressStream compresses src through the codec's streaming writer and appends the result to dst
+func compressStream(c Codec, dst, src []byte, level int) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, err := c.NewWriter(buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressStream decompresses src through the codec's streaming reader and appends the result to dst
+func decompressStream(c Codec, dst, src []byte) ([]byte, error) {
+	r, err := c.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(dst)
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	if err := r.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resetWriter resets streaming writers that follow the Reset(io.Writer) convention
+func resetWriter(zw io.WriteCloser, w io.Writer) error {
+	rw, ok := zw.(interface{ Reset(io.Writer) })
+	if !ok {
+		return fmt.Errorf("compression: %T cannot be reset", zw)
+	}
+	rw.Reset(w)
+	return nil
+}
+
+// resetReader resets streaming readers with a Reset(io.Reader) or Reset(io.Reader, dict) method
+func resetReader(zr io.ReadCloser, r io.Reader) error {
+	switch rr := zr.(type) {
+	case interface{ Reset(io.Reader) error }:
+		return rr.Reset(r)
+	case interface{ Reset(io.Reader, []byte) error }:
+		return rr.Reset(r, nil)
+	}
+	return fmt.Errorf("compression: %T cannot be reset", zr)
+}
@@ -0,0 +1,297 @@
+package compression
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Dimensions of the codec benchmark matrix
+var (
+	benchmarkSizes     = []int{SmallSize, MediumSize, LargeSize}
+	benchmarkDataTypes = DataTypes
+)
+
+// TestMain prints unavailable codecs and, for benchmarks, user input checksums as benchstat configuration lines
+func TestMain(m *testing.M) {
+	flag.Parse()
+	skipped := SkippedCodecs()
+	for _, name := range slices.Sorted(maps.Keys(skipped)) {
+		fmt.Printf("skipped-codec-%s: %s\n", name, skipped[name])
+	}
+
+	if flag.Lookup("test.bench").Value.String() != "" {
+		announceCorpus = true
+		if err := announceInputs(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	os.Exit(m.Run())
+}
+
+// testCorpus serves every benchmark's input, persisted under $COMPRESSION_CORPUS_DIR when it is set
+var testCorpus = NewCorpus(os.Getenv(CorpusDirEnv))
+
+// announceCorpus makes testData print the checksum of each data set it loads, once per data set
+var (
+	announceCorpus  bool
+	announcedCorpus sync.Map
+)
+
+// testData returns the corpus data for a size and type at DefaultSeed
+func testData(tb testing.TB, size int, dataType string) []byte {
+	tb.Helper()
+	if strings.HasPrefix(dataType, inputPrefix) {
+		return inputData(tb, size, dataType)
+	}
+	data, err := testCorpus.Get(size, dataType, DefaultSeed)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if announceCorpus {
+		name := fmt.Sprintf("corpus-%s-%s-seed%d", dataType, sizeLabel(size), DefaultSeed)
+		if _, loaded := announcedCorpus.LoadOrStore(name, true); !loaded {
+			sum, err := testCorpus.Checksum(size, dataType, DefaultSeed)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			fmt.Printf("%s: sha256:%s\n", name, sum)
+		}
+	}
+	return data
+}
+
+// benchInputs returns the files under $COMPRESSION_INPUTS, loaded once per process, or nil when it is not set
+var benchInputs = sync.OnceValues(func() ([]Input, error) {
+	path := os.Getenv(InputsEnv)
+	if path == "" {
+		return nil, nil
+	}
+	return LoadInputs(path, InputSampleLimit, InputTotalLimit)
+})
+
+// announceInputs prints the checksum and original size of each user-supplied input
+func announceInputs() error {
+	inputs, err := benchInputs()
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", InputsEnv, err)
+	}
+	for _, in := range inputs {
+		name := strings.TrimPrefix(in.Name, inputPrefix)
+		fmt.Printf("input-%s: sha256:%s\n", name, checksum(in.Data))
+		fmt.Printf("input-%s-source-bytes: %d\n", name, in.SourceSize)
+	}
+	return nil
+}
+
+// userInputs returns the user-supplied inputs, failing the benchmark if they cannot be read
+func userInputs(tb testing.TB) []Input {
+	tb.Helper()
+	inputs, err := benchInputs()
+	if err != nil {
+		tb.Fatalf("loading %s: %v", InputsEnv, err)
+	}
+	return inputs
+}
+
+// inputData returns a user-supplied input
+func inputData(tb testing.TB, size int, name string) []byte {
+	tb.Helper()
+	for _, in := range userInputs(tb) {
+		if in.Name != name {
+			continue
+		}
+		if len(in.Data) != size {
+			tb.Fatalf("%s is %d bytes, not %d", name, len(in.Data), size)
+		}
+		return in.Data
+	}
+	tb.Fatalf("no input named %s", name)
+	return nil
+}
+
+// runCodecMatrix runs fn as a codec=<name>/size=<size>/data=<type>/level=<level> sub-benchmark for every codec
+func runCodecMatrix(b *testing.B, sizes []int, fn func(b *testing.B, c Codec, size int, dataType string, level int)) {
+	codecMatrix(b, sizes, nil, fn)
+}
+
+// runCodecMatrixWithInputs is runCodecMatrix followed by every user-supplied input at its own size
+func runCodecMatrixWithInputs(b *testing.B, sizes []int, fn func(b *testing.B, c Codec, size int, dataType string, level int)) {
+	codecMatrix(b, sizes, userInputs(b), fn)
+}
+
+func codecMatrix(b *testing.B, sizes []int, inputs []Input, fn func(b *testing.B, c Codec, size int, dataType string, level int)) {
+	for _, c := range Codecs() {
+		b.Run("codec="+c.Name(), func(b *testing.B) {
+			for _, size := range sizes {
+				b.Run("size="+sizeLabel(size), func(b *testing.B) {
+					for _, dataType := range benchmarkDataTypes {
+						runLevels(b, c, size, dataType, fn)
+					}
+				})
+			}
+			for _, in := range inputs {
+				b.Run("size="+sizeLabel(len(in.Data)), func(b *testing.B) {
+					runLevels(b, c, len(in.Data), in.Name, fn)
+				})
+			}
+		})
+	}
+}
+
+// runLevels runs fn as a data=<type>/level=<level> sub-benchmark for every level of a codec
+func runLevels(b *testing.B, c Codec, size int, dataType string, fn func(b *testing.B, c Codec, size int, dataType string, level int)) {
+	b.Run("data="+dataType, func(b *testing.B) {
+		for _, level := range c.Levels() {
+			b.Run(fmt.Sprintf("level=%d", level), func(b *testing.B) {
+				logLevel(b, c, level)
+				fn(b, c, size, dataType, level)
+			})
+		}
+	})
+}
+
+// logLevel records which library setting a numeric level maps to for codecs that describe their levels
+func logLevel(b *testing.B, c Codec, level int) {
+	if d, ok := c.(LevelDescriber); ok && b.N == 1 {
+		b.Logf("%s level %d runs as %s", c.Name(), level, d.DescribeLevel(level))
+	}
+}
+
+// BenchmarkCompression measures compression speed and ratio across the codec matrix
+func BenchmarkCompression(b *testing.B) {
+	runCodecMatrixWithInputs(b, benchmarkSizes, benchmarkCompress)
+}
+
+// BenchmarkDecompression measures decompression speed across the codec matrix
+func BenchmarkDecompression(b *testing.B) {
+	runCodecMatrixWithInputs(b, benchmarkSizes, benchmarkDecompress)
+}
+
+// benchmarkCompress measures compression speed of a codec at the given level and reports the ratio achieved
+func benchmarkCompress(b *testing.B, c Codec, size int, dataType string, level int) {
+	benchmarkCompressData(b, c, testData(b, size, dataType), level)
+}
+
+// benchmarkCompressData is benchmarkCompress for data that does not come from the corpus
+func benchmarkCompressData(b *testing.B, c Codec, data []byte, level int) {
+	var compressed []byte
+	var err error
+
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		compressed, err = c.Compress(nil, data, level)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(len(data))/float64(len(compressed)), "ratio")
+	reportMemory(b, func() error {
+		_, err := c.Compress(nil, data, level)
+		return err
+	})
+}
+
+// benchmarkDecompress measures decompression speed of data compressed by a codec at the given level
+func benchmarkDecompress(b *testing.B, c Codec, size int, dataType string, level int) {
+	benchmarkDecompressData(b, c, testData(b, size, dataType), level)
+}
+
+// benchmarkDecompressData is benchmarkDecompress for data that does not come from the corpus
+func benchmarkDecompressData(b *testing.B, c Codec, data []byte, level int) {
+	compressed, err := c.Compress(nil, data, level)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// Verify decompression
+	decompressed, err := c.Decompress(nil, compressed)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if !bytes.Equal(data, decompressed) {
+		b.Fatal("Decompressed data does not match original")
+	}
+
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Decompress(nil, compressed); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	reportMemory(b, func() error {
+		_, err := c.Decompress(nil, compressed)
+		return err
+	})
+}
+
+// measuredMemory holds the memoryStats of each benchmark by name
+var measuredMemory sync.Map
+
+// reportMemory measures op once per benchmark and reports its peak-heap-B and peak-rss-B when they are reliable
+func reportMemory(b *testing.B, op func() error) {
+	v, ok := measuredMemory.Load(b.Name())
+	if !ok {
+		var err error
+		stats := measureMemory(func() { err = op() })
+		if err != nil {
+			b.Fatal(err)
+		}
+		v, _ = measuredMemory.LoadOrStore(b.Name(), stats)
+	}
+	stats := v.(memoryStats)
+	sampled := stats.samples >= memoryMinSamples
+	if sampled {
+		b.ReportMetric(float64(stats.peakHeap), "peak-heap-B")
+	}
+	if stats.rssKnown && (sampled || stats.hwmReset) {
+		b.ReportMetric(float64(stats.peakRSS), "peak-rss-B")
+	}
+}
+
+// TestCodecAppendsToDst checks that Compress and Decompress append to dst
+func TestCodecAppendsToDst(t *testing.T) {
+	data := testData(t, 64<<10, TextData)
+	prefix := []byte("prefix")
+	for _, c := range Codecs() {
+		level := c.Levels()[0]
+		t.Run("codec="+c.Name(), func(t *testing.T) {
+			compressed, err := c.Compress(nil, data, level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, spare := range []int{0, 2 * len(data)} {
+				dst := append(make([]byte, 0, len(prefix)+spare), prefix...)
+				got, err := c.Compress(dst, data, level)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.HasPrefix(got, prefix) || !bytes.Equal(got[len(prefix):], compressed) {
+					t.Errorf("Compress with %d spare bytes did not append to dst", spare)
+				}
+
+				dst = append(make([]byte, 0, len(prefix)+spare), prefix...)
+				got, err = c.Decompress(dst, compressed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.HasPrefix(got, prefix) || !bytes.Equal(got[len(prefix):], data) {
+					t.Errorf("Decompress with %d spare bytes did not append to dst", spare)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package compression
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CorpusDirEnv names the environment variable that points the benchmarks at an on-disk corpus cache
+const CorpusDirEnv = "COMPRESSION_CORPUS_DIR"
+
+// corpusVersion is part of every cache path; bump it whenever GenerateTestDataSeed output changes
+const corpusVersion = 2
+
+// corpusMemoryLimit bounds the data a Corpus keeps in memory
+const corpusMemoryLimit = 256 << 20
+
+// Corpus hands out GenerateTestDataSeed output, cached in memory and optionally on disk; it is safe for concurrent use
+type Corpus struct {
+	dir   string
+	limit int
+
+	mu       sync.Mutex
+	entries  map[corpusKey]*corpusEntry
+	resident int    // bytes of data held by entries
+	clock    uint64 // incremented on every use, for LRU eviction
+}
+
+type corpusKey struct {
+	size     int
+	dataType string
+	seed     uint64
+}
+
+// corpusEntry is one data set; data is nil until loaded and again once evicted
+type corpusEntry struct {
+	loading sync.Mutex
+	data    []byte
+	sum     string
+	err     error
+	lastUse uint64
+}
+
+// NewCorpus returns a corpus cached in memory and, if dir is not empty, under dir
+func NewCorpus(dir string) *Corpus {
+	return &Corpus{dir: dir, limit: corpusMemoryLimit, entries: make(map[corpusKey]*corpusEntry)}
+}
+
+// Get returns the data for a size, type and seed
+func (c *Corpus) Get(size int, dataType string, seed uint64) ([]byte, error) {
+	data, _, err := c.get(corpusKey{size, dataType, seed}, true)
+	return data, err
+}
+
+// Checksum returns the hex SHA-256 of the data for a size, type and seed. It does not reload evicted data.
+func (c *Corpus) Checksum(size int, dataType string, seed uint64) (string, error) {
+	_, sum, err := c.get(corpusKey{size, dataType, seed}, false)
+	return sum, err
+}
+
+// get returns an entry's data and checksum, loading the data only if needData is set or the checksum is unknown
+func (c *Corpus) get(key corpusKey, needData bool) ([]byte, string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &corpusEntry{}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	e.loading.Lock()
+	defer e.loading.Unlock()
+
+	c.mu.Lock()
+	if e.err != nil || e.data != nil || (!needData && e.sum != "") {
+		c.clock++
+		e.lastUse = c.clock
+		data, sum, err := e.data, e.sum, e.err
+		c.mu.Unlock()
+		return data, sum, err
+	}
+	c.mu.Unlock()
+
+	data, sum, err := c.load(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.data, e.sum, e.err = data, sum, err
+	c.clock++
+	e.lastUse = c.clock
+	c.resident += len(data)
+	c.evict(e)
+	return data, sum, err
+}
+
+// evict drops least recently used data sets other than keep until the corpus is within its limit; c.mu must be held
+func (c *Corpus) evict(keep *corpusEntry) {
+	for c.resident > c.limit {
+		var oldest *corpusEntry
+		for _, e := range c.entries {
+			if e != keep && e.data != nil && (oldest == nil || e.lastUse < oldest.lastUse) {
+				oldest = e
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		c.resident -= len(oldest.data)
+		oldest.data = nil
+	}
+}
+
+// load reads verified data from the cache directory, or generates it and stores it there
+func (c *Corpus) load(key corpusKey) ([]byte, string, error) {
+	if !slices.Contains(DataTypes, key.dataType) {
+		return nil, "", fmt.Errorf("compression: unknown data type %q (want one of %v)", key.dataType, DataTypes)
+	}
+
+	if c.dir != "" {
+		data, sum, err := c.read(key)
+		if err == nil {
+			return data, sum, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, errCorpusChecksum) {
+			return nil, "", err
+		}
+	}
+
+	data := GenerateTestDataSeed(key.size, key.dataType, key.seed)
+	sum := checksum(data)
+	if c.dir != "" {
+		if err := c.write(key, data, sum); err != nil {
+			return nil, "", err
+		}
+	}
+	return data, sum, nil
+}
+
+// errCorpusChecksum reports cached data whose content no longer matches its hash
+var errCorpusChecksum = errors.New("compression: cached corpus data does not match its checksum")
+
+// indexPath is <dir>/v<version>/<type>-<size>-seed<seed>.sha256, naming the hash of the data
+func (c *Corpus) indexPath(key corpusKey) string {
+	name := fmt.Sprintf("%s-%s-seed%d.sha256", key.dataType, sizeLabel(key.size), key.seed)
+	return filepath.Join(c.dir, fmt.Sprintf("v%d", corpusVersion), name)
+}
+
+// blobPath is <dir>/v<version>/<sha256>.bin
+func (c *Corpus) blobPath(sum string) string {
+	return filepath.Join(c.dir, fmt.Sprintf("v%d", corpusVersion), sum+".bin")
+}
+
+func (c *Corpus) read(key corpusKey) ([]byte, string, error) {
+	index, err := os.ReadFile(c.indexPath(key))
+	if err != nil {
+		return nil, "", err
+	}
+	sum := strings.TrimSpace(string(index))
+	data, err := os.ReadFile(c.blobPath(sum))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) != key.size || checksum(data) != sum {
+		return nil, "", errCorpusChecksum
+	}
+	return data, sum, nil
+}
+
+func (c *Corpus) write(key corpusKey, data []byte, sum string) error {
+	if err := os.MkdirAll(filepath.Dir(c.blobPath(sum)), 0o755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.blobPath(sum), data); err != nil {
+		return err
+	}
+	return writeFileAtomic(c.indexPath(key), []byte(sum+"\n"))
+}
+
+// writeFileAtomic writes through a temporary file and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// checksum returns the hex SHA-256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,160 @@
+package compression
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// corpusGoldenSize is small enough to regenerate on every test run
+const corpusGoldenSize = 64 << 10
+
+// corpusGolden pins the SHA-256 of each data type; changing it requires bumping corpusVersion
+var corpusGolden = map[string]string{
+	RandomData: "2b81db95009819a9369fa4dd78ca03c4ff637260e162ca9fc8c3211fc4fe4866",
+	TextData:   "85921917f82ef901d72c29d08d67dbc8de6b3afb34988354a5927d3be6611cc6",
+	BinaryData: "bc1e43981eabc3afb426f86645724da634febdb26754f4086eb5c8b15296629a",
+	LogData:    "8712ee1e90af8a066b77b5d53626b21c7e301d85dacd73c65976d9b1c1143d84",
+	CSVData:    "41e52becd9ec660f29086361e39ce74ede1a32129824a9695716ea392f1186c6",
+	SourceData: "c9bb0d89fac159f2a123a3c465133a0c39fb1acf027a61a5bb382470a4c89cb9",
+	NDJSONData: "3ec0d831fdd0fa3dc0d04f163697ace9e55d71640a57cee2d8b326ed79f15182",
+	VarintData: "725f73996a9693dcc3be9f0319725b6c1ea7769bde32dcd4eb5aa4026e587a2e",
+}
+
+// TestCorpusGolden checks that every data type still generates the pinned bytes
+func TestCorpusGolden(t *testing.T) {
+	corpus := NewCorpus("")
+	for _, dataType := range DataTypes {
+		sum, err := corpus.Checksum(corpusGoldenSize, dataType, DefaultSeed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := corpusGolden[dataType]; sum != want {
+			t.Errorf("%s data checksum is %s, want %s", dataType, sum, want)
+		}
+	}
+}
+
+// TestCorpusDeterministic checks that output depends only on size, type and seed
+func TestCorpusDeterministic(t *testing.T) {
+	for _, dataType := range DataTypes {
+		a := GenerateTestDataSeed(corpusGoldenSize, dataType, 7)
+		b := GenerateTestDataSeed(corpusGoldenSize, dataType, 7)
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s data differs between calls with the same seed", dataType)
+		}
+		if bytes.Equal(a, GenerateTestDataSeed(corpusGoldenSize, dataType, 8)) {
+			t.Errorf("%s data is the same for different seeds", dataType)
+		}
+		if len(a) != corpusGoldenSize {
+			t.Errorf("%s data is %d bytes, want %d", dataType, len(a), corpusGoldenSize)
+		}
+	}
+}
+
+// TestCorpusPrefix checks that smaller sizes of a type are prefixes of larger ones
+func TestCorpusPrefix(t *testing.T) {
+	for _, dataType := range DataTypes {
+		large := GenerateTestDataSeed(corpusGoldenSize, dataType, DefaultSeed)
+		for _, size := range []int{1, 100, 4 << 10, corpusGoldenSize - 1} {
+			if !bytes.Equal(large[:size], GenerateTestDataSeed(size, dataType, DefaultSeed)) {
+				t.Errorf("%s data at %d bytes is not a prefix of %d bytes", dataType, size, corpusGoldenSize)
+			}
+		}
+	}
+}
+
+// TestCorpusEviction checks LRU eviction, that checksums survive it and that evicted data is read back from disk
+func TestCorpusEviction(t *testing.T) {
+	corpus := NewCorpus(t.TempDir())
+	corpus.limit = 2 * corpusGoldenSize
+	for _, dataType := range []string{TextData, LogData, CSVData} {
+		if _, err := corpus.Get(corpusGoldenSize, dataType, DefaultSeed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resident := func(dataType string) bool {
+		corpus.mu.Lock()
+		defer corpus.mu.Unlock()
+		return corpus.entries[corpusKey{corpusGoldenSize, dataType, DefaultSeed}].data != nil
+	}
+	if resident(TextData) || !resident(LogData) || !resident(CSVData) {
+		t.Fatal("the least recently used data set was not the one evicted")
+	}
+	if corpus.resident > corpus.limit {
+		t.Errorf("corpus holds %d bytes, limit is %d", corpus.resident, corpus.limit)
+	}
+
+	if sum, err := corpus.Checksum(corpusGoldenSize, TextData, DefaultSeed); err != nil || sum != corpusGolden[TextData] {
+		t.Errorf("checksum of evicted data is %q, %v; want %s", sum, err, corpusGolden[TextData])
+	}
+	if resident(TextData) {
+		t.Error("Checksum reloaded evicted data")
+	}
+	got, err := corpus.Get(corpusGoldenSize, TextData, DefaultSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, GenerateTestDataSeed(corpusGoldenSize, TextData, DefaultSeed)) {
+		t.Error("evicted data did not reload to the same bytes")
+	}
+	if resident(LogData) {
+		t.Error("reloading did not evict the next least recently used data set")
+	}
+}
+
+// TestCorpusCache checks that a cache directory is reused across corpora and that damaged files are regenerated
+func TestCorpusCache(t *testing.T) {
+	dir := t.TempDir()
+	want := GenerateTestDataSeed(corpusGoldenSize, TextData, DefaultSeed)
+
+	first := NewCorpus(dir)
+	if _, err := first.Get(corpusGoldenSize, TextData, DefaultSeed); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := first.Checksum(corpusGoldenSize, TextData, DefaultSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := first.blobPath(sum)
+	if _, err := os.Stat(blob); err != nil {
+		t.Fatalf("data was not cached: %v", err)
+	}
+
+	// A second corpus must read the same bytes back from disk
+	got, err := NewCorpus(dir).Get(corpusGoldenSize, TextData, DefaultSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Fatal("cached data does not match generated data")
+	}
+
+	// Damaged files fail verification and are replaced
+	if err := os.WriteFile(blob, []byte("damaged"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = NewCorpus(dir).Get(corpusGoldenSize, TextData, DefaultSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Fatal("damaged cache file was not regenerated")
+	}
+	if cached, _ := os.ReadFile(blob); !bytes.Equal(want, cached) {
+		t.Fatal("damaged cache file was not rewritten")
+	}
+
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(blob), "*.tmp*")); len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+// TestCorpusUnknownType checks that a typo in a data type is reported instead of producing empty input
+func TestCorpusUnknownType(t *testing.T) {
+	if _, err := NewCorpus("").Get(corpusGoldenSize, "txet", DefaultSeed); err == nil {
+		t.Fatal("expected an error for an unknown data type")
+	}
+}
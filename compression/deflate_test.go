@@ -0,0 +1,155 @@
+package compression
+
+import (
+	"compress/flate"
+	"compress/zlib"
+	"io"
+
+	kflate "github.com/klauspost/compress/flate"
+	kzlib "github.com/klauspost/compress/zlib"
+)
+
+// Raw DEFLATE and zlib share the gzip compression core, so they are benchmarked at gzipLevels
+
+func init() {
+	RegisterCodec(stdlibFlateCodec{})
+	RegisterCodec(klauspostFlateCodec{})
+	RegisterCodec(stdlibZlibCodec{})
+	RegisterCodec(klauspostZlibCodec{})
+}
+
+// stdlibFlateCodec adapts compress/flate (raw DEFLATE) to the Codec interface
+type stdlibFlateCodec struct{}
+
+func (stdlibFlateCodec) Name() string  { return "flate-stdlib" }
+func (stdlibFlateCodec) Levels() []int { return gzipLevels }
+
+func (c stdlibFlateCodec) Compress(dst, src []byte, level int) ([]byte, error) {
+	return compressStream(c, dst, src, level)
+}
+
+func (c stdlibFlateCodec) Decompress(dst, src []byte) ([]byte, error) {
+	return decompressStream(c, dst, src)
+}
+
+func (stdlibFlateCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	fw, err := flate.NewWriter(w, level)
+	if err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+func (stdlibFlateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+func (stdlibFlateCodec) ResetWriter(zw io.WriteCloser, w io.Writer) error {
+	return resetWriter(zw, w)
+}
+
+func (stdlibFlateCodec) ResetReader(zr io.ReadCloser, r io.Reader) error {
+	return resetReader(zr, r)
+}
+
+// klauspostFlateCodec adapts github.com/klauspost/compress/flate to the Codec interface
+type klauspostFlateCodec struct{}
+
+func (klauspostFlateCodec) Name() string  { return "flate-klauspost" }
+func (klauspostFlateCodec) Levels() []int { return gzipLevels }
+
+func (c klauspostFlateCodec) Compress(dst, src []byte, level int) ([]byte, error) {
+	return compressStream(c, dst, src, level)
+}
+
+func (c klauspostFlateCodec) Decompress(dst, src []byte) ([]byte, error) {
+	return decompressStream(c, dst, src)
+}
+
+func (klauspostFlateCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	fw, err := kflate.NewWriter(w, level)
+	if err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+func (klauspostFlateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return kflate.NewReader(r), nil
+}
+
+func (klauspostFlateCodec) ResetWriter(zw io.WriteCloser, w io.Writer) error {
+	return resetWriter(zw, w)
+}
+
+func (klauspostFlateCodec) ResetReader(zr io.ReadCloser, r io.Reader) error {
+	return resetReader(zr, r)
+}
+
+// stdlibZlibCodec adapts the standard library compress/zlib (PNG, git objects) to the Codec interface
+type stdlibZlibCodec struct{}
+
+func (stdlibZlibCodec) Name() string  { return "zlib-stdlib" }
+func (stdlibZlibCodec) Levels() []int { return gzipLevels }
+
+func (c stdlibZlibCodec) Compress(dst, src []byte, level int) ([]byte, error) {
+	return compressStream(c, dst, src, level)
+}
+
+func (c stdlibZlibCodec) Decompress(dst, src []byte) ([]byte, error) {
+	return decompressStream(c, dst, src)
+}
+
+func (stdlibZlibCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	zw, err := zlib.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	return zw, nil
+}
+
+func (stdlibZlibCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+func (stdlibZlibCodec) ResetWriter(zw io.WriteCloser, w io.Writer) error {
+	return resetWriter(zw, w)
+}
+
+func (stdlibZlibCodec) ResetReader(zr io.ReadCloser, r io.Reader) error {
+	return resetReader(zr, r)
+}
+
+// klauspostZlibCodec adapts github.com/klauspost/compress/zlib to the Codec interface
+type klauspostZlibCodec struct{}
+
+func (klauspostZlibCodec) Name() string  { return "zlib-klauspost" }
+func (klauspostZlibCodec) Levels() []int { return gzipLevels }
+
+func (c klauspostZlibCodec) Compress(dst, src []byte, level int) ([]byte, error) {
+	return compressStream(c, dst, src, level)
+}
+
+func (c klauspostZlibCodec) Decompress(dst, src []byte) ([]byte, error) {
+	return decompressStream(c, dst, src)
+}
+
+func (klauspostZlibCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	zw, err := kzlib.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	return zw, nil
+}
+
+func (klauspostZlibCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return kzlib.NewReader(r)
+}
+
+func (klauspostZlibCodec) ResetWriter(zw io.WriteCloser, w io.Writer) error {
+	return resetWriter(zw, w)
+}
+
+func (klauspostZlibCodec) ResetReader(zr io.ReadCloser, r io.Reader) error {
+	return resetReader(zr, r)
+}
@@ -0,0 +1,350 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/klauspost/compress/dict"
+	klauspost "github.com/klauspost/compress/zstd"
+)
+
+// Dictionary training parameters; training records use a different seed from benchmark records
+const (
+	dictTrainingRecords = 500
+	dictBenchRecords    = 1000
+	dictTrainingSeed    = 42
+	dictBenchSeed       = 4242
+	dictMaxSize         = 32 << 10
+	dictID              = 0x6c6f67 // arbitrary but fixed so frames are reproducible
+)
+
+// dictRecord is a small, similar-shaped message typical of RPC and queue payloads
+type dictRecord struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Method    string    `json:"method"`
+	URL       string    `json:"url"`
+	Status    int       `json:"status"`
+	LatencyMS int       `json:"latency_ms"`
+	ClientIP  string    `json:"client_ip"`
+	UserAgent string    `json:"user_agent"`
+	User      string    `json:"user"`
+	Message   string    `json:"message"`
+}
+
+// generateDictRecords creates count JSON records deterministically from seed
+func generateDictRecords(count int, seed uint64) [][]byte {
+	faker := gofakeit.New(seed)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	records := make([][]byte, count)
+	for i := range records {
+		record, err := json.Marshal(dictRecord{
+			ID:        faker.UUID(),
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Level:     faker.LogLevel("general"),
+			Method:    faker.HTTPMethod(),
+			URL:       faker.URL(),
+			Status:    faker.HTTPStatusCodeSimple(),
+			LatencyMS: faker.IntRange(1, 2000),
+			ClientIP:  faker.IPv4Address(),
+			UserAgent: faker.UserAgent(),
+			User:      faker.Username(),
+			Message:   faker.Sentence(faker.IntRange(4, 12)),
+		})
+		if err != nil {
+			panic(err)
+		}
+		records[i] = record
+	}
+	return records
+}
+
+// dictOptions configures the dictionary builder
+var dictOptions = dict.Options{
+	MaxDictSize:    dictMaxSize,
+	HashBytes:      6,
+	ZstdDictID:     dictID,
+	ZstdDictCompat: true,
+}
+
+// Training takes seconds, so the dictionary is built once per process
+var (
+	trainedDictOnce sync.Once
+	trainedDict     []byte
+	trainedDictErr  error
+)
+
+// trainZstdDictionary returns a zstd dictionary built from the training records with klauspost's builder
+func trainZstdDictionary(tb testing.TB) []byte {
+	tb.Helper()
+	trainedDictOnce.Do(func() {
+		trainedDict, trainedDictErr = dict.BuildZstdDict(generateDictRecords(dictTrainingRecords, dictTrainingSeed), dictOptions)
+	})
+	if trainedDictErr != nil {
+		tb.Fatal(trainedDictErr)
+	}
+	return trainedDict
+}
+
+// trainZDICTDictionary returns a dictionary trained by the zstd command, skipping when it is not on PATH
+func trainZDICTDictionary(tb testing.TB) []byte {
+	tb.Helper()
+	zstdPath, err := exec.LookPath("zstd")
+	if err != nil {
+		tb.Skip("zstd command not found; it is needed to train a ZDICT dictionary")
+	}
+
+	dir := tb.TempDir()
+	samples := make([]string, 0, dictTrainingRecords)
+	for i, r := range generateDictRecords(dictTrainingRecords, dictTrainingSeed) {
+		path := filepath.Join(dir, fmt.Sprintf("record-%04d.json", i))
+		if err := os.WriteFile(path, r, 0o644); err != nil {
+			tb.Fatal(err)
+		}
+		samples = append(samples, path)
+	}
+
+	out := filepath.Join(dir, "zdict")
+	args := append([]string{"-q", "--train", "--maxdict=" + strconv.Itoa(dictMaxSize),
+		"--dictID=" + strconv.Itoa(dictID), "-o", out}, samples...)
+	if output, err := exec.Command(zstdPath, args...).CombinedOutput(); err != nil {
+		tb.Fatalf("zstd --train: %v\n%s", err, output)
+	}
+	d, err := os.ReadFile(out)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return d
+}
+
+// recordCodec compresses individual records with an optional dictionary
+type recordCodec struct {
+	compress   func(dst, src []byte) ([]byte, error)
+	decompress func(dst, src []byte) ([]byte, error)
+	close      func()
+}
+
+// dictImplementation creates a recordCodec for a level and dictionary; a nil dictionary disables it
+type dictImplementation struct {
+	name string
+	new  func(level int, d []byte) (recordCodec, error)
+}
+
+// dictImplementations lists the record codecs compared; DataDog is added in cgo builds
+var dictImplementations = []dictImplementation{
+	{"zstd-klauspost", newKlauspostRecordCodec},
+}
+
+func newKlauspostRecordCodec(level int, d []byte) (recordCodec, error) {
+	encoderLevel, err := klauspostZstdLevel(level)
+	if err != nil {
+		return recordCodec{}, err
+	}
+	eopts := []klauspost.EOption{klauspost.WithEncoderLevel(encoderLevel)}
+	var dopts []klauspost.DOption
+	if d != nil {
+		eopts = append(eopts, klauspost.WithEncoderDict(d))
+		dopts = append(dopts, klauspost.WithDecoderDicts(d))
+	}
+
+	enc, err := klauspost.NewWriter(nil, eopts...)
+	if err != nil {
+		return recordCodec{}, err
+	}
+	dec, err := klauspost.NewReader(nil, dopts...)
+	if err != nil {
+		enc.Close()
+		return recordCodec{}, err
+	}
+	return recordCodec{
+		compress: func(dst, src []byte) ([]byte, error) {
+			return enc.EncodeAll(src, dst), nil
+		},
+		decompress: func(dst, src []byte) ([]byte, error) {
+			return dec.DecodeAll(src, dst)
+		},
+		close: func() {
+			enc.Close()
+			dec.Close()
+		},
+	}, nil
+}
+
+// BenchmarkZstdDictionaryTraining measures building a dictionary from the training records
+func BenchmarkZstdDictionaryTraining(b *testing.B) {
+	records := generateDictRecords(dictTrainingRecords, dictTrainingSeed)
+	var total int
+	for _, r := range records {
+		total += len(r)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.SetBytes(int64(total))
+	for i := 0; i < b.N; i++ {
+		if _, err := dict.BuildZstdDict(records, dictOptions); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkZstdDictionaryCompression compresses records one at a time with and without a trained dictionary
+func BenchmarkZstdDictionaryCompression(b *testing.B) {
+	runDictionaryMatrix(b, benchmarkDictionaryCompress)
+}
+
+// BenchmarkZstdDictionaryDecompression decompresses records one at a time with and without a trained dictionary
+func BenchmarkZstdDictionaryDecompression(b *testing.B) {
+	runDictionaryMatrix(b, benchmarkDictionaryDecompress)
+}
+
+// runDictionaryMatrix runs fn for every implementation, with and without the dictionary, at every zstd level
+func runDictionaryMatrix(b *testing.B, fn func(b *testing.B, rc recordCodec, records [][]byte)) {
+	trained := trainZstdDictionary(b)
+	records := generateDictRecords(dictBenchRecords, dictBenchSeed)
+
+	for _, impl := range dictImplementations {
+		b.Run("codec="+impl.name, func(b *testing.B) {
+			for _, mode := range []string{"none", "trained"} {
+				b.Run("dict="+mode, func(b *testing.B) {
+					var d []byte
+					if mode == "trained" {
+						d = trained
+					}
+					for _, level := range zstdLevels {
+						b.Run(fmt.Sprintf("level=%d", level), func(b *testing.B) {
+							rc, err := impl.new(level, d)
+							if err != nil {
+								b.Fatal(err)
+							}
+							defer rc.close()
+							fn(b, rc, records)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+func benchmarkDictionaryCompress(b *testing.B, rc recordCodec, records [][]byte) {
+	var raw, compressed int
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		raw, compressed = 0, 0
+		for _, r := range records {
+			var err error
+			buf, err = rc.compress(buf[:0], r)
+			if err != nil {
+				b.Fatal(err)
+			}
+			raw += len(r)
+			compressed += len(buf)
+		}
+	}
+	b.StopTimer()
+
+	b.SetBytes(int64(raw))
+	b.ReportMetric(float64(raw)/float64(compressed), "ratio")
+	b.ReportMetric(float64(compressed)/float64(len(records)), "B/record")
+}
+
+func benchmarkDictionaryDecompress(b *testing.B, rc recordCodec, records [][]byte) {
+	frames := make([][]byte, len(records))
+	var raw int
+	for i, r := range records {
+		frame, err := rc.compress(nil, r)
+		if err != nil {
+			b.Fatal(err)
+		}
+		// Verify decompression
+		out, err := rc.decompress(nil, frame)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if !bytes.Equal(r, out) {
+			b.Fatal("Decompressed record does not match original")
+		}
+		frames[i] = frame
+		raw += len(r)
+	}
+
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.SetBytes(int64(raw))
+	for i := 0; i < b.N; i++ {
+		for _, frame := range frames {
+			var err error
+			buf, err = rc.decompress(buf[:0], frame)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// TestZstdDictionaryInterop checks that dictionary-compressed records decode in every implementation
+func TestZstdDictionaryInterop(t *testing.T) {
+	records := generateDictRecords(100, dictBenchSeed)
+	trainers := []struct {
+		name  string
+		train func(tb testing.TB) []byte
+	}{
+		{"klauspost", trainZstdDictionary},
+		{"zdict", trainZDICTDictionary},
+	}
+
+	for _, trainer := range trainers {
+		t.Run("dict="+trainer.name, func(t *testing.T) {
+			trained := trainer.train(t)
+			for _, producer := range dictImplementations {
+				for _, consumer := range dictImplementations {
+					t.Run(producer.name+"->"+consumer.name, func(t *testing.T) {
+						checkDictionaryInterop(t, producer, consumer, trained, records)
+					})
+				}
+			}
+		})
+	}
+}
+
+func checkDictionaryInterop(t *testing.T, producer, consumer dictImplementation, trained []byte, records [][]byte) {
+	enc, err := producer.new(3, trained)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.close()
+	dec, err := consumer.new(3, trained)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.close()
+
+	for i, r := range records {
+		frame, err := enc.compress(nil, r)
+		if err != nil {
+			t.Fatalf("record %d: compress: %v", i, err)
+		}
+		out, err := dec.decompress(nil, frame)
+		if err != nil {
+			t.Fatalf("record %d: decompress: %v", i, err)
+		}
+		if !bytes.Equal(r, out) {
+			t.Fatalf("record %d: round trip mismatch", i)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/klauspost/compress/fse"
+	"github.com/klauspost/compress/huff0"
+)
+
+// entropyStageSize is the input size of the entropy-stage benchmarks
+const entropyStageSize = SmallSize
+
+// entropyBlockSize is the block every coder compresses independently, zstd's maximum block size
+const entropyBlockSize = 128 << 10
+
+// Column value distributions, modelling columnar pages after dictionary or delta encoding
+const (
+	columnEnum   = "column-enum"   // dictionary indexes of a Zipf-distributed category with 64 values
+	columnBool   = "column-bool"   // booleans as 0 or 1, true 10% of the time
+	columnDelta  = "column-delta"  // exponentially distributed deltas of sorted timestamps, capped at 255
+	columnDigits = "column-digits" // prices as newline-separated decimal text
+	columnFloat  = "column-float"  // second-highest byte plane of normally distributed float64 values
+)
+
+var columnTypes = []string{columnEnum, columnBool, columnDelta, columnDigits, columnFloat}
+
+// entropyStageDataTypes are the generated data types followed by the column distributions
+var entropyStageDataTypes = append(slices.Clone(benchmarkDataTypes), columnTypes...)
+
+// generateColumn returns size bytes of a column distribution
+func generateColumn(size int, columnType string, seed uint64) []byte {
+	rng := rand.New(rand.NewPCG(seed, 0))
+	data := make([]byte, 0, size)
+	switch columnType {
+	case columnEnum:
+		zipf := rand.NewZipf(rng, 1.2, 1, 63)
+		for len(data) < size {
+			data = append(data, byte(zipf.Uint64()))
+		}
+	case columnBool:
+		for len(data) < size {
+			data = append(data, byte(rng.IntN(10)/9))
+		}
+	case columnDelta:
+		for len(data) < size {
+			data = append(data, byte(min(rng.ExpFloat64()*4, 255)))
+		}
+	case columnDigits:
+		for len(data) < size {
+			data = strconv.AppendFloat(data, float64(rng.IntN(1000000))/100, 'f', 2, 64)
+			data = append(data, '\n')
+		}
+	case columnFloat:
+		for len(data) < size {
+			data = append(data, byte(math.Float64bits(100+rng.NormFloat64()*15)>>48))
+		}
+	default:
+		panic("unknown column type " + columnType)
+	}
+	return data[:size]
+}
+
+// entropyStageData returns corpus data for generated data types and column data for column types
+func entropyStageData(tb testing.TB, size int, dataType string) []byte {
+	tb.Helper()
+	if slices.Contains(columnTypes, dataType) {
+		return generateColumn(size, dataType, DefaultSeed)
+	}
+	return testData(tb, size, dataType)
+}
+
+// entropyStage compresses and decompresses independent blocks
+type entropyStage struct {
+	name       string
+	compress   func(block []byte) ([]byte, error)
+	decompress func(dst, src []byte) ([]byte, error)
+}
+
+// entropyStages returns fresh huff0, FSE, gzip and zstd coders
+func entropyStages() []entropyStage {
+	return []entropyStage{
+		newHuff0Stage("huff0-1x", huff0.Compress1X, (*huff0.Decoder).Decompress1X),
+		newHuff0Stage("huff0-4x", huff0.Compress4X, (*huff0.Decoder).Decompress4X),
+		newFSEStage(),
+		newCodecStage(LookupCodec("gzip-klauspost")),
+		newCodecStage(LookupCodec(klauspostZstd.Name())),
+	}
+}
+
+// newHuff0Stage disables table reuse so every block carries its own table and decodes on its own
+func newHuff0Stage(name string, compress func([]byte, *huff0.Scratch) ([]byte, bool, error),
+	decompress func(*huff0.Decoder, []byte, []byte) ([]byte, error)) entropyStage {
+	enc := &huff0.Scratch{Reuse: huff0.ReusePolicyNone}
+	dec := &huff0.Scratch{}
+	return entropyStage{
+		name: name,
+		compress: func(block []byte) ([]byte, error) {
+			out, _, err := compress(block, enc)
+			return out, err
+		},
+		decompress: func(dst, src []byte) ([]byte, error) {
+			s, remain, err := huff0.ReadTable(src, dec)
+			if err != nil {
+				return nil, err
+			}
+			return decompress(s.Decoder(), dst, remain)
+		},
+	}
+}
+
+func newFSEStage() entropyStage {
+	enc, dec := &fse.Scratch{}, &fse.Scratch{}
+	return entropyStage{
+		name: "fse",
+		compress: func(block []byte) ([]byte, error) {
+			return fse.Compress(block, enc)
+		},
+		// Decompress writes into dec.Out, so pointing it at dst decodes in place
+		decompress: func(dst, src []byte) ([]byte, error) {
+			dec.Out = dst[:0]
+			return fse.Decompress(src, dec)
+		},
+	}
+}
+
+func newCodecStage(c Codec, ok bool) entropyStage {
+	if !ok {
+		panic("compression: reference codec is not registered")
+	}
+	level := c.Levels()[0]
+	return entropyStage{
+		name: c.Name(),
+		compress: func(block []byte) ([]byte, error) {
+			return c.Compress(nil, block, level)
+		},
+		decompress: func(dst, src []byte) ([]byte, error) {
+			return c.Decompress(dst, src)
+		},
+	}
+}
+
+// How an entropy-coded block is stored
+const (
+	blockCoded = iota // output of the coder
+	blockRaw          // the coder cannot shrink the block
+	blockRLE          // the block repeats a single byte
+)
+
+// entropyBlock is one compressed block
+type entropyBlock struct {
+	mode byte
+	data []byte // coded data, the raw block, or the repeated byte
+	size int    // decompressed size
+}
+
+// storedSize is the size of the block in a container, including a mode and size header
+func (b entropyBlock) storedSize() int {
+	var header [1 + 2*binary.MaxVarintLen64]byte
+	n := len(binary.AppendUvarint(binary.AppendUvarint(header[:1], uint64(len(b.data))), uint64(b.size)))
+	return n + len(b.data)
+}
+
+// compressBlocks compresses data in independent blocks and returns them with their total stored size
+func compressBlocks(s entropyStage, data []byte) ([]entropyBlock, int, error) {
+	var blocks []entropyBlock
+	total := 0
+	for off := 0; off < len(data); off += entropyBlockSize {
+		block := data[off:min(off+entropyBlockSize, len(data))]
+		out, err := s.compress(block)
+		b := entropyBlock{mode: blockCoded, data: slices.Clone(out), size: len(block)}
+		switch {
+		case errors.Is(err, huff0.ErrIncompressible), errors.Is(err, fse.ErrIncompressible):
+			b.mode, b.data = blockRaw, block
+		case errors.Is(err, huff0.ErrUseRLE), errors.Is(err, fse.ErrUseRLE):
+			b.mode, b.data = blockRLE, block[:1]
+		case err != nil:
+			return nil, 0, err
+		}
+		blocks = append(blocks, b)
+		total += b.storedSize()
+	}
+	return blocks, total, nil
+}
+
+// decompressBlocks decodes blocks into dst, which must hold the decompressed data
+func decompressBlocks(s entropyStage, dst []byte, blocks []entropyBlock) error {
+	off := 0
+	for i, b := range blocks {
+		out := dst[off : off : off+b.size]
+		switch b.mode {
+		case blockCoded:
+			decoded, err := s.decompress(out, b.data)
+			if err != nil {
+				return fmt.Errorf("block %d: %w", i, err)
+			}
+			if len(decoded) != b.size {
+				return fmt.Errorf("block %d: decoded %d bytes, want %d", i, len(decoded), b.size)
+			}
+			// Codecs that do not append to an empty dst return their own buffer
+			if b.size > 0 && &decoded[0] != &dst[off] {
+				copy(dst[off:], decoded)
+			}
+		case blockRaw:
+			copy(dst[off:], b.data)
+		case blockRLE:
+			for j := range b.size {
+				dst[off+j] = b.data[0]
+			}
+		}
+		off += b.size
+	}
+	return nil
+}
+
+// runEntropyStageMatrix runs fn as a coder=<name>/size=1MB/data=<type> sub-benchmark
+func runEntropyStageMatrix(b *testing.B, fn func(b *testing.B, s entropyStage, data []byte)) {
+	for _, s := range entropyStages() {
+		b.Run("coder="+s.name, func(b *testing.B) {
+			b.Run("size="+sizeLabel(entropyStageSize), func(b *testing.B) {
+				for _, dataType := range entropyStageDataTypes {
+					b.Run("data="+dataType, func(b *testing.B) {
+						fn(b, s, entropyStageData(b, entropyStageSize, dataType))
+					})
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkEntropyStageCompression measures huff0 and FSE on their own against full gzip and zstd
+func BenchmarkEntropyStageCompression(b *testing.B) {
+	runEntropyStageMatrix(b, func(b *testing.B, s entropyStage, data []byte) {
+		var total int
+		var err error
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			if _, total, err = compressBlocks(s, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StopTimer()
+
+		b.ReportMetric(float64(len(data))/float64(total), "ratio")
+	})
+}
+
+// BenchmarkEntropyStageDecompression measures decoding the same blocks into a preallocated buffer
+func BenchmarkEntropyStageDecompression(b *testing.B) {
+	runEntropyStageMatrix(b, func(b *testing.B, s entropyStage, data []byte) {
+		blocks, _, err := compressBlocks(s, data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		out := make([]byte, len(data))
+
+		// Verify decompression
+		if err := decompressBlocks(s, out, blocks); err != nil {
+			b.Fatal(err)
+		}
+		if !bytes.Equal(data, out) {
+			b.Fatal("Decompressed data does not match original")
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			if err := decompressBlocks(s, out, blocks); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// TestEntropyStages round-trips every data type through every coder and checks the RLE and raw fallbacks
+func TestEntropyStages(t *testing.T) {
+	const size = 3*entropyBlockSize + 1234
+	constant := bytes.Repeat([]byte{'x'}, entropyBlockSize)
+	random := GenerateTestData(entropyBlockSize, RandomData)
+	for _, s := range entropyStages() {
+		for _, dataType := range entropyStageDataTypes {
+			t.Run(fmt.Sprintf("coder=%s/data=%s", s.name, dataType), func(t *testing.T) {
+				data := entropyStageData(t, size, dataType)
+				blocks, total, err := compressBlocks(s, data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(blocks) != 4 || total > len(data)+len(blocks)*64 {
+					t.Fatalf("%d bytes in %d blocks", total, len(blocks))
+				}
+				out := make([]byte, len(data))
+				if err := decompressBlocks(s, out, blocks); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(data, out) {
+					t.Fatal("decompressed data does not match original")
+				}
+			})
+		}
+
+		if s.name == "huff0-1x" || s.name == "huff0-4x" || s.name == "fse" {
+			for _, tc := range []struct {
+				data []byte
+				mode byte
+			}{{constant, blockRLE}, {random, blockRaw}} {
+				blocks, _, err := compressBlocks(s, tc.data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if blocks[0].mode != tc.mode {
+					t.Errorf("%s: block stored as mode %d, want %d", s.name, blocks[0].mode, tc.mode)
+				}
+			}
+		}
+	}
+}
+
+// TestColumnData checks that column distributions are reproducible and compress as their model implies
+func TestColumnData(t *testing.T) {
+	for _, columnType := range columnTypes {
+		data := generateColumn(SmallSize, columnType, DefaultSeed)
+		if len(data) != SmallSize || !bytes.Equal(data, generateColumn(SmallSize, columnType, DefaultSeed)) {
+			t.Errorf("%s: column is not reproducible at the requested size", columnType)
+		}
+		_, total, err := compressBlocks(newFSEStage(), data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ratio := float64(len(data)) / float64(total); ratio < 1.5 {
+			t.Errorf("%s: FSE ratio is %.2f, want a skewed distribution", columnType, ratio)
+		}
+	}
+}
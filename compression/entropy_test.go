@@ -0,0 +1,73 @@
+package compression
+
+import (
+	"fmt"
+	"testing"
+)
+
+// entropySize keeps the sweep affordable across every codec, level and step
+const entropySize = SmallSize
+
+// entropySteps are the randomness percentages swept, fine enough to plot curves
+var entropySteps = []int{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+
+// entropyData returns GenerateEntropyData output for every step at DefaultSeed
+func entropyData() map[int][]byte {
+	data := make(map[int][]byte, len(entropySteps))
+	for _, pct := range entropySteps {
+		data[pct] = GenerateEntropyData(entropySize, float64(pct)/100, DefaultSeed)
+	}
+	return data
+}
+
+// BenchmarkEntropyCompression measures compression as data goes from fully repetitive to fully random
+func BenchmarkEntropyCompression(b *testing.B) {
+	runEntropySweep(b, benchmarkCompressData)
+}
+
+// BenchmarkEntropyDecompression measures decompression speed across the same sweep
+func BenchmarkEntropyDecompression(b *testing.B) {
+	runEntropySweep(b, benchmarkDecompressData)
+}
+
+// runEntropySweep runs fn for every codec, randomness step and level
+func runEntropySweep(b *testing.B, fn func(b *testing.B, c Codec, data []byte, level int)) {
+	data := entropyData()
+	for _, c := range Codecs() {
+		b.Run("codec="+c.Name(), func(b *testing.B) {
+			for _, pct := range entropySteps {
+				b.Run(fmt.Sprintf("random=%d", pct), func(b *testing.B) {
+					for _, level := range c.Levels() {
+						b.Run(fmt.Sprintf("level=%d", level), func(b *testing.B) {
+							logLevel(b, c, level)
+							fn(b, c, data[pct], level)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+// TestEntropyData checks that compressibility falls steadily as randomness rises
+func TestEntropyData(t *testing.T) {
+	data := entropyData()
+	prev := 0
+	for _, pct := range entropySteps {
+		if len(data[pct]) != entropySize {
+			t.Fatalf("random=%d: got %d bytes, want %d", pct, len(data[pct]), entropySize)
+		}
+		compressed, err := klauspostZstd.Compress(nil, data[pct], 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(compressed) <= prev {
+			t.Errorf("random=%d compresses to %d bytes, no more than the previous step's %d", pct, len(compressed), prev)
+		}
+		prev = len(compressed)
+		t.Logf("random=%d: ratio %.2f", pct, float64(entropySize)/float64(len(compressed)))
+	}
+	if ratio := float64(entropySize) / float64(prev); ratio > 1.01 {
+		t.Errorf("random=100 compresses with ratio %.3f", ratio)
+	}
+}
@@ -0,0 +1,251 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// generatorEpoch is the first timestamp of generated data, fixed so output does not depend on the clock
+var generatorEpoch = time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+
+// generateLogs writes CI job output with go test -v results
+func generateLogs(faker *gofakeit.Faker, size int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(size)
+	ts := generatorEpoch
+	line := func(format string, args ...any) {
+		ts = ts.Add(time.Duration(faker.IntRange(100, 250_000)) * time.Microsecond)
+		buf.WriteString(ts.Format("2006-01-02T15:04:05.0000000Z"))
+		buf.WriteByte(' ')
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteByte('\n')
+	}
+
+	module := "github.com/" + strings.ToLower(faker.Username()) + "/" + strings.ToLower(faker.Noun())
+	for buf.Len() < size {
+		switch faker.IntRange(0, 9) {
+		case 0:
+			line("##[group]Run %s", faker.RandomString([]string{"actions/checkout@v4", "actions/setup-go@v5", "go mod download", "go vet ./...", "make lint"}))
+			line("##[endgroup]")
+		case 1:
+			line("go: downloading %s v%d.%d.%d", faker.DomainName()+"/"+strings.ToLower(faker.Noun()), faker.IntRange(0, 3), faker.IntRange(0, 30), faker.IntRange(0, 12))
+		case 2:
+			line("##[warning]%s", faker.Sentence(faker.IntRange(4, 10)))
+		default:
+			pkg := module + "/" + strings.ToLower(faker.Noun())
+			for range faker.IntRange(1, 8) {
+				test := "Test" + camelCase(faker, 2)
+				elapsed := float64(faker.IntRange(0, 3000)) / 1000
+				line("=== RUN   %s", test)
+				if faker.IntRange(0, 19) == 0 {
+					line("    %s_test.go:%d: %s", strings.ToLower(faker.Noun()), faker.IntRange(10, 900), faker.Sentence(faker.IntRange(3, 8)))
+					line("--- FAIL: %s (%.2fs)", test, elapsed)
+				} else {
+					line("--- PASS: %s (%.2fs)", test, elapsed)
+				}
+			}
+			line("ok  \t%s\t%.3fs\tcoverage: %.1f%% of statements", pkg, float64(faker.IntRange(5, 20000))/1000, float64(faker.IntRange(0, 1000))/10)
+		}
+	}
+	return buf.Bytes()
+}
+
+// generateCSV writes a table of orders with a header row, quoting fields the way encoding/csv does
+func generateCSV(faker *gofakeit.Faker, size int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(size)
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"order_id", "created_at", "customer", "email", "city", "country", "product", "quantity", "unit_price", "status"})
+
+	ts := generatorEpoch
+	for id := 100000; buf.Len() < size; id++ {
+		ts = ts.Add(time.Duration(faker.IntRange(1, 600)) * time.Second)
+		w.Write([]string{
+			strconv.Itoa(id),
+			ts.Format(time.RFC3339),
+			faker.Name(),
+			faker.Email(),
+			faker.City(),
+			faker.CountryAbr(),
+			faker.ProductName(),
+			strconv.Itoa(faker.IntRange(1, 20)),
+			strconv.FormatFloat(faker.Price(1, 500), 'f', 2, 64),
+			faker.RandomString([]string{"pending", "paid", "shipped", "delivered", "refunded"}),
+		})
+		if id%64 == 0 {
+			w.Flush()
+		}
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// goImports are the packages generated Go files import; the first three are always used
+var goImports = []string{"context", "errors", "fmt", "io", "net/http", "os", "sort", "strconv", "strings", "sync", "time"}
+
+// generateGoSource writes gofmt-style Go files
+func generateGoSource(faker *gofakeit.Faker, size int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(size)
+	for buf.Len() < size {
+		typeName := camelCase(faker, 2)
+		recv := strings.ToLower(typeName[:1])
+
+		pkg := identifier(faker)
+		fmt.Fprintf(&buf, "// Package %s %s.\npackage %s\n\nimport (\n", pkg, phrase(faker, 6), pkg)
+		extra := faker.IntRange(3, len(goImports)-3)
+		for _, imp := range append(goImports[:3:3], goImports[extra:extra+faker.IntRange(0, 3)]...) {
+			fmt.Fprintf(&buf, "\t%q\n", imp)
+		}
+		buf.WriteString(")\n\n")
+
+		fields := make([]string, faker.IntRange(2, 6))
+		fmt.Fprintf(&buf, "// %s %s.\ntype %s struct {\n", typeName, phrase(faker, 8), typeName)
+		for i := range fields {
+			fields[i] = camelCase(faker, 1)
+			fmt.Fprintf(&buf, "\t// %s %s\n\t%s %s\n", fields[i], phrase(faker, 5), fields[i],
+				faker.RandomString([]string{"string", "int", "int64", "bool", "[]byte", "time.Duration", "map[string]string", "*sync.Mutex"}))
+		}
+		buf.WriteString("}\n\n")
+
+		fmt.Fprintf(&buf, "// New%s returns a %s ready for use.\nfunc New%s(%s string) (*%s, error) {\n", typeName, typeName, typeName, strings.ToLower(fields[0]), typeName)
+		fmt.Fprintf(&buf, "\tif %s == \"\" {\n\t\treturn nil, errors.New(%q)\n\t}\n", strings.ToLower(fields[0]), strings.ToLower(fields[0])+" is required")
+		fmt.Fprintf(&buf, "\treturn &%s{%s: %s}, nil\n}\n\n", typeName, fields[0], strings.ToLower(fields[0]))
+
+		for range faker.IntRange(1, 4) {
+			method := camelCase(faker, 2)
+			fmt.Fprintf(&buf, "// %s %s.\nfunc (%s *%s) %s(ctx context.Context, n int) (int, error) {\n", method, phrase(faker, 7), recv, typeName, method)
+			fmt.Fprintf(&buf, "\ttotal := 0\n\tfor i := 0; i < n; i++ {\n\t\tif err := ctx.Err(); err != nil {\n\t\t\treturn total, fmt.Errorf(\"%s: %%w\", err)\n\t\t}\n", strings.ToLower(method))
+			fmt.Fprintf(&buf, "\t\ttotal += i * %d\n\t}\n", faker.IntRange(1, 64))
+			fmt.Fprintf(&buf, "\tif total > %d {\n\t\treturn 0, fmt.Errorf(\"%s: %%d exceeds limit\", total)\n\t}\n\treturn total, nil\n}\n\n", faker.IntRange(1000, 1000000), strings.ToLower(method))
+		}
+	}
+	return buf.Bytes()
+}
+
+// ndjsonEvent is one line of generated NDJSON. A struct rather than a map keeps the key order fixed.
+type ndjsonEvent struct {
+	Time      string  `json:"ts"`
+	Type      string  `json:"type"`
+	UserID    int     `json:"user_id"`
+	Session   string  `json:"session"`
+	IP        string  `json:"ip"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Status    int     `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	UserAgent string  `json:"user_agent"`
+}
+
+// generateNDJSON writes an event stream with one JSON object per line, as shipped by log and analytics pipelines
+func generateNDJSON(faker *gofakeit.Faker, size int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(size)
+	enc := json.NewEncoder(&buf)
+	ts := generatorEpoch
+	session := faker.UUID()
+	for buf.Len() < size {
+		ts = ts.Add(time.Duration(faker.IntRange(1, 2000)) * time.Millisecond)
+		if faker.IntRange(0, 9) == 0 {
+			session = faker.UUID()
+		}
+		enc.Encode(ndjsonEvent{
+			Time:      ts.Format(time.RFC3339Nano),
+			Type:      faker.RandomString([]string{"page_view", "click", "search", "add_to_cart", "checkout"}),
+			UserID:    faker.IntRange(1, 50000),
+			Session:   session,
+			IP:        faker.IPv4Address(),
+			Method:    faker.HTTPMethod(),
+			Path:      "/" + strings.ToLower(faker.Noun()) + "/" + strconv.Itoa(faker.IntRange(1, 9999)),
+			Status:    faker.HTTPStatusCodeSimple(),
+			LatencyMS: float64(faker.IntRange(1, 500000)) / 1000,
+			UserAgent: faker.UserAgent(),
+		})
+	}
+	return buf.Bytes()
+}
+
+// generateVarintRecords writes length-prefixed records in protobuf wire format
+func generateVarintRecords(faker *gofakeit.Faker, size int) []byte {
+	data := make([]byte, 0, size)
+	var record []byte
+	ts := generatorEpoch.UnixMilli()
+	for id := uint64(1); len(data) < size; id++ {
+		ts += int64(faker.IntRange(1, 5000))
+
+		record = record[:0]
+		record = appendVarintField(record, 1, id)
+		record = appendVarintField(record, 2, uint64(ts))
+		record = appendBytesField(record, 3, []byte(faker.Name()))
+		record = appendVarintField(record, 4, zigzag(int64(faker.IntRange(-1000, 1000))))
+		record = binary.AppendUvarint(record, 5<<3|1) // fixed64
+		record = binary.LittleEndian.AppendUint64(record, math.Float64bits(faker.Float64Range(0, 1)))
+		var packed []byte
+		for range faker.IntRange(0, 8) {
+			packed = binary.AppendUvarint(packed, uint64(faker.IntRange(0, 300)))
+		}
+		record = appendBytesField(record, 6, packed)
+
+		data = binary.AppendUvarint(data, uint64(len(record)))
+		data = append(data, record...)
+	}
+	return data
+}
+
+// appendVarintField appends a protobuf varint field
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendBytesField appends a protobuf length-delimited field
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// zigzag maps signed integers to unsigned ones the way protobuf sint64 does
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// phrase returns a lower-case sentence of n words without its final period, for use inside comments
+func phrase(faker *gofakeit.Faker, n int) string {
+	return strings.TrimSuffix(strings.ToLower(faker.Sentence(n)), ".")
+}
+
+// camelCase joins nouns from the faker into an exported Go identifier
+func camelCase(faker *gofakeit.Faker, words int) string {
+	var sb strings.Builder
+	for range words {
+		word := identifier(faker)
+		sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return sb.String()
+}
+
+// identifier returns a lower-case noun that is a valid Go identifier and not a keyword
+func identifier(faker *gofakeit.Faker) string {
+	for {
+		word := strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' {
+				return r
+			}
+			return -1
+		}, strings.ToLower(faker.Noun()))
+		if word != "" && !token.IsKeyword(word) {
+			return word
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"testing"
+)
+
+// generatorTestSize holds a few hundred records of every structured type
+const generatorTestSize = 256 << 10
+
+// logLine matches one line of generated CI output
+var logLine = regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{7}Z (##\[|go: downloading |=== RUN |--- (PASS|FAIL): |ok  \t|    \w+_test\.go:\d+: )`)
+
+// TestGeneratedDataWellFormed parses the structured data types, ignoring the cut-off last record
+func TestGeneratedDataWellFormed(t *testing.T) {
+	t.Run("data="+LogData, func(t *testing.T) {
+		for _, line := range completeLines(t, LogData) {
+			if !logLine.Match(line) {
+				t.Fatalf("unexpected log line %q", line)
+			}
+		}
+	})
+
+	t.Run("data="+CSVData, func(t *testing.T) {
+		data := GenerateTestData(generatorTestSize, CSVData)
+		records, err := csv.NewReader(bytes.NewReader(data[:bytes.LastIndexByte(data, '\n')+1])).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) < 100 || records[0][0] != "order_id" {
+			t.Fatalf("got %d records with header %v", len(records), records[0])
+		}
+	})
+
+	t.Run("data="+SourceData, func(t *testing.T) {
+		data := GenerateTestData(generatorTestSize, SourceData)
+		files := bytes.SplitAfter(data, []byte("\n\n// Package "))
+		for i, src := range files[:len(files)-1] {
+			src = bytes.TrimSuffix(src, []byte("// Package "))
+			if i > 0 {
+				src = append([]byte("// Package "), src...)
+			}
+			if _, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments); err != nil {
+				t.Fatalf("file %d does not parse: %v", i, err)
+			}
+		}
+		if len(files) < 10 {
+			t.Fatalf("got %d files", len(files))
+		}
+	})
+
+	t.Run("data="+NDJSONData, func(t *testing.T) {
+		for _, line := range completeLines(t, NDJSONData) {
+			var event ndjsonEvent
+			if err := json.Unmarshal(line, &event); err != nil {
+				t.Fatalf("line %q: %v", line, err)
+			}
+		}
+	})
+
+	t.Run("data="+VarintData, func(t *testing.T) {
+		data := GenerateTestData(generatorTestSize, VarintData)
+		count := 0
+		for {
+			n, k := binary.Uvarint(data)
+			if k <= 0 || uint64(len(data)-k) < n {
+				break // incomplete final record
+			}
+			if err := checkVarintRecord(data[k : k+int(n)]); err != nil {
+				t.Fatalf("record %d: %v", count, err)
+			}
+			data = data[k+int(n):]
+			count++
+		}
+		if count < 100 {
+			t.Fatalf("decoded only %d records", count)
+		}
+	})
+}
+
+// completeLines returns every newline-terminated line of a generated data type
+func completeLines(t *testing.T, dataType string) [][]byte {
+	t.Helper()
+	data := GenerateTestData(generatorTestSize, dataType)
+	lines := bytes.Split(data, []byte("\n"))
+	return lines[:len(lines)-1]
+}
+
+// checkVarintRecord walks a record's fields, checking that field numbers and wire types match the generator
+func checkVarintRecord(record []byte) error {
+	wantTypes := map[uint64]uint64{1: 0, 2: 0, 3: 2, 4: 0, 5: 1, 6: 2}
+	for len(record) > 0 {
+		key, k := binary.Uvarint(record)
+		if k <= 0 {
+			return errVarint
+		}
+		record = record[k:]
+		field, wireType := key>>3, key&7
+		if want, ok := wantTypes[field]; !ok || want != wireType {
+			return errVarint
+		}
+		switch wireType {
+		case 0:
+			if _, k = binary.Uvarint(record); k <= 0 {
+				return errVarint
+			}
+			record = record[k:]
+		case 1:
+			if len(record) < 8 {
+				return errVarint
+			}
+			record = record[8:]
+		case 2:
+			n, k := binary.Uvarint(record)
+			if k <= 0 || uint64(len(record)-k) < n {
+				return errVarint
+			}
+			record = record[k+int(n):]
+		}
+	}
+	return nil
+}
+
+// errVarint reports a record that does not follow the generator's schema
+var errVarint = errors.New("malformed protobuf field")
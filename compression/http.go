@@ -0,0 +1,166 @@
+package compression
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentEncoder is a Content-Encoding that CompressHandler can apply to responses
+type ContentEncoder struct {
+	Name      string // Content-Encoding token, e.g. gzip or zstd
+	NewWriter func(w io.Writer) (ResettableWriter, error)
+}
+
+// ResettableWriter is a streaming encoder that can be pooled across responses
+type ResettableWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// CompressHandler encodes responses of next with the first of encoders that the request accepts
+func CompressHandler(next http.Handler, encoders ...ContentEncoder) http.Handler {
+	pools := make([]*sync.Pool, len(encoders))
+	names := make([]string, len(encoders))
+	for i, e := range encoders {
+		pools[i] = &sync.Pool{}
+		names[i] = e.Name
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		i := NegotiateEncoding(r.Header.Get("Accept-Encoding"), names)
+		if i < 0 || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressResponseWriter{ResponseWriter: w, encoder: encoders[i], pool: pools[i]}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressResponseWriter starts encoding when the final status is written
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoder     ContentEncoder
+	pool        *sync.Pool
+	zw          ResettableWriter
+	wroteHeader bool
+	err         error
+}
+
+func (cw *compressResponseWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	// Informational responses precede the final status, which decides the encoding
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.wroteHeader = true
+	h := cw.Header()
+	if bodyAllowed(code) && h.Get("Content-Encoding") == "" {
+		if zw, ok := cw.pool.Get().(ResettableWriter); ok {
+			zw.Reset(cw.ResponseWriter)
+			cw.zw = zw
+		} else {
+			cw.zw, cw.err = cw.encoder.NewWriter(cw.ResponseWriter)
+		}
+		// The headers only announce the encoding once there is an encoder to apply it
+		if cw.err == nil {
+			h.Del("Content-Length")
+			h.Set("Content-Encoding", cw.encoder.Name)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Flush sends the data written so far through the encoder to the client
+func (cw *compressResponseWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := cw.zw.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			cw.err = err
+			return
+		}
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressResponseWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	if cw.zw == nil {
+		return cw.ResponseWriter.Write(p)
+	}
+	return cw.zw.Write(p)
+}
+
+// close flushes the encoded body and returns the encoder to the pool
+func (cw *compressResponseWriter) close() {
+	if cw.zw == nil {
+		return
+	}
+	if err := cw.zw.Close(); err == nil {
+		cw.pool.Put(cw.zw)
+	}
+}
+
+// bodyAllowed reports whether a response with this status carries a body
+func bodyAllowed(code int) bool {
+	return code >= 200 && code != http.StatusNoContent && code != http.StatusNotModified
+}
+
+// NegotiateEncoding returns the index of the first of supported that acceptEncoding allows, or -1
+func NegotiateEncoding(acceptEncoding string, supported []string) int {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		accepted[coding] = qValue(params) > 0
+	}
+	for i, name := range supported {
+		ok, listed := accepted[strings.ToLower(name)]
+		if !listed {
+			ok = accepted["*"]
+		}
+		if ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// qValue parses the q parameter of an Accept-Encoding element, defaulting to 1
+func qValue(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(strings.TrimSpace(key), "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return 0
+			}
+			return q
+		}
+	}
+	return 1
+}
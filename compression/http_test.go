@@ -0,0 +1,485 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/gzhttp"
+	kgzip "github.com/klauspost/compress/gzip"
+	klauspost "github.com/klauspost/compress/zstd"
+)
+
+// HTTP benchmark dimensions. Response sizes run from small API responses to large documents.
+var (
+	httpResponseSizes = []int{1 << 10, 16 << 10, 256 << 10, SmallSize}
+	httpDataTypes     = []string{httpJSONData, TextData}
+)
+
+// httpJSONData is a JSON array of the events in NDJSONData, as an API would return them
+const httpJSONData = "json"
+
+// httpAcceptEncoding is the Accept-Encoding header current browsers send
+const httpAcceptEncoding = "gzip, deflate, br, zstd"
+
+// httpMiddleware wraps a handler in response compression
+type httpMiddleware struct {
+	name     string
+	encoding string // Content-Encoding the middleware negotiates with httpAcceptEncoding
+	wrap     func(h http.Handler) (http.Handler, error)
+}
+
+// httpMiddlewares compress every response at their library's default level
+var httpMiddlewares = []httpMiddleware{
+	{"identity", "", func(h http.Handler) (http.Handler, error) { return h, nil }},
+	{"gzip-stdlib", "gzip", compressMiddleware(ContentEncoder{"gzip", newStdlibGzipResponseWriter})},
+	{"gzip-klauspost", "gzip", compressMiddleware(ContentEncoder{"gzip", newKlauspostGzipResponseWriter})},
+	{"gzhttp", "gzip", newGzhttpMiddleware},
+	{"zstd-klauspost", "zstd", compressMiddleware(ContentEncoder{"zstd", newKlauspostZstdResponseWriter})},
+}
+
+func compressMiddleware(e ContentEncoder) func(h http.Handler) (http.Handler, error) {
+	return func(h http.Handler) (http.Handler, error) {
+		return CompressHandler(h, e), nil
+	}
+}
+
+func newStdlibGzipResponseWriter(w io.Writer) (ResettableWriter, error) {
+	return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+}
+
+func newKlauspostGzipResponseWriter(w io.Writer) (ResettableWriter, error) {
+	return kgzip.NewWriterLevel(w, kgzip.DefaultCompression)
+}
+
+// newKlauspostZstdResponseWriter uses one goroutine per response, as concurrent requests already keep every core busy
+func newKlauspostZstdResponseWriter(w io.Writer) (ResettableWriter, error) {
+	return klauspost.NewWriter(w, klauspost.WithEncoderLevel(klauspost.SpeedDefault), klauspost.WithEncoderConcurrency(1))
+}
+
+func newGzhttpMiddleware(h http.Handler) (http.Handler, error) {
+	wrap, err := gzhttp.NewWrapper(gzhttp.MinSize(0), gzhttp.CompressionLevel(kgzip.DefaultCompression))
+	if err != nil {
+		return nil, err
+	}
+	return wrap(h), nil
+}
+
+// httpBody returns a response body of at most size bytes
+func httpBody(tb testing.TB, size int, dataType string) ([]byte, string) {
+	tb.Helper()
+	if dataType != httpJSONData {
+		return testData(tb, size, dataType), "text/plain; charset=utf-8"
+	}
+
+	body := []byte{'['}
+	for _, event := range bytes.Split(testData(tb, size, NDJSONData), []byte{'\n'}) {
+		if len(body)+len(event)+2 > size {
+			break
+		}
+		if len(body) > 1 {
+			body = append(body, ',')
+		}
+		body = append(body, event...)
+	}
+	return append(body, ']'), "application/json"
+}
+
+// staticHandler serves body the way a typical handler does, with a Content-Length the middleware must drop
+func staticHandler(body []byte, contentType string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	})
+}
+
+// httpClient fetches and decodes responses over a keep-alive connection, counting every byte the server sends
+type httpClient struct {
+	client *http.Client
+	wire   atomic.Int64 // bytes read from the connection: status line, headers, framing and body
+	gz     *kgzip.Reader
+	zd     *klauspost.Decoder
+	body   bytes.Buffer
+}
+
+// countingConn counts the bytes read from a connection
+type countingConn struct {
+	net.Conn
+	n *atomic.Int64
+}
+
+func (c countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func newHTTPClient(tb testing.TB) *httpClient {
+	tb.Helper()
+	c := &httpClient{}
+	var dialer net.Dialer
+	transport := &http.Transport{
+		// Responses are decoded below, so the transport must not negotiate and decode gzip itself
+		DisableCompression: true,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return countingConn{conn, &c.wire}, nil
+		},
+	}
+	tb.Cleanup(transport.CloseIdleConnections)
+	c.client = &http.Client{Transport: transport}
+
+	zd, err := klauspost.NewReader(nil, klauspost.WithDecoderConcurrency(1))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(zd.Close)
+	c.zd = zd
+	return c
+}
+
+// get fetches req and returns its Content-Encoding and decoded body. The body is only valid until the next call.
+func (c *httpClient) get(req *http.Request) (string, []byte, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	encoding := resp.Header.Get("Content-Encoding")
+	var body io.Reader
+	switch encoding {
+	case "":
+		body = resp.Body
+	case "gzip":
+		if c.gz == nil {
+			c.gz, err = kgzip.NewReader(resp.Body)
+		} else {
+			err = c.gz.Reset(resp.Body)
+		}
+		body = c.gz
+	case "zstd":
+		err = c.zd.Reset(resp.Body)
+		body = c.zd
+	default:
+		err = fmt.Errorf("unexpected Content-Encoding %q", encoding)
+	}
+	if err != nil {
+		return encoding, nil, err
+	}
+
+	c.body.Reset()
+	if _, err := c.body.ReadFrom(body); err != nil {
+		return encoding, nil, err
+	}
+	// Drain the response so the connection is reused
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return encoding, nil, err
+	}
+	return encoding, c.body.Bytes(), nil
+}
+
+// newHTTPServer serves body through a middleware and returns a GET request for it with httpAcceptEncoding
+func newHTTPServer(tb testing.TB, m httpMiddleware, body []byte, contentType string) *http.Request {
+	tb.Helper()
+	h, err := m.wrap(staticHandler(body, contentType))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	tb.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", httpAcceptEncoding)
+	return req
+}
+
+// BenchmarkHTTPCompression measures end-to-end latency and bytes on the wire of compressed GET requests
+func BenchmarkHTTPCompression(b *testing.B) {
+	for _, m := range httpMiddlewares {
+		b.Run("middleware="+m.name, func(b *testing.B) {
+			for _, size := range httpResponseSizes {
+				b.Run("size="+sizeLabel(size), func(b *testing.B) {
+					for _, dataType := range httpDataTypes {
+						b.Run("data="+dataType, func(b *testing.B) {
+							benchmarkHTTP(b, m, size, dataType)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+func benchmarkHTTP(b *testing.B, m httpMiddleware, size int, dataType string) {
+	body, contentType := httpBody(b, size, dataType)
+	req := newHTTPServer(b, m, body, contentType)
+	client := newHTTPClient(b)
+
+	// Verify the response and warm up the connection and encoder pools
+	encoding, got, err := client.get(req)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if encoding != m.encoding {
+		b.Fatalf("Content-Encoding is %q, want %q", encoding, m.encoding)
+	}
+	if !bytes.Equal(body, got) {
+		b.Fatal("Decoded response does not match the body")
+	}
+
+	client.wire.Store(0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.SetBytes(int64(len(body)))
+	for i := 0; i < b.N; i++ {
+		if _, _, err := client.get(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(client.wire.Load())/float64(b.N), "wire-B/op")
+}
+
+// TestHTTPCompression checks that every middleware negotiates its encoding and round-trips the body
+func TestHTTPCompression(t *testing.T) {
+	for _, m := range httpMiddlewares {
+		for _, dataType := range httpDataTypes {
+			t.Run(fmt.Sprintf("middleware=%s/data=%s", m.name, dataType), func(t *testing.T) {
+				body, contentType := httpBody(t, 16<<10, dataType)
+				req := newHTTPServer(t, m, body, contentType)
+				client := newHTTPClient(t)
+
+				for _, accept := range []string{httpAcceptEncoding, "identity", ""} {
+					req.Header.Set("Accept-Encoding", accept)
+					encoding, got, err := client.get(req)
+					if err != nil {
+						t.Fatal(err)
+					}
+					want := ""
+					if accept == httpAcceptEncoding {
+						want = m.encoding
+					}
+					if encoding != want {
+						t.Errorf("Accept-Encoding %q: Content-Encoding is %q, want %q", accept, encoding, want)
+					}
+					if !bytes.Equal(body, got) {
+						t.Errorf("Accept-Encoding %q: decoded response does not match the body", accept)
+					}
+				}
+			})
+		}
+	}
+}
+
+// serveCompressed records the response of handler behind CompressHandler to a request accepting gzip
+func serveCompressed(rec *httptest.ResponseRecorder, handler http.HandlerFunc, encoders ...ContentEncoder) {
+	if len(encoders) == 0 {
+		encoders = []ContentEncoder{{"gzip", newStdlibGzipResponseWriter}}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	CompressHandler(handler, encoders...).ServeHTTP(rec, req)
+}
+
+// TestCompressHandlerFlush checks that flushed data decodes before the response ends
+func TestCompressHandlerFlush(t *testing.T) {
+	first, second := []byte("first part of the response"), []byte(", and the rest")
+	rec := httptest.NewRecorder()
+	serveCompressed(rec, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(first)
+		w.(http.Flusher).Flush()
+		if !rec.Flushed {
+			t.Error("Flush did not flush the underlying writer")
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, len(first))
+		if _, err := io.ReadFull(zr, got); err != nil || !bytes.Equal(got, first) {
+			t.Errorf("flushed body decodes to %q, %v; want %q", got, err, first)
+		}
+		w.Write(second)
+	})
+
+	if encoding := rec.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("Content-Encoding is %q, want gzip", encoding)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(first, second...); !bytes.Equal(got, want) {
+		t.Errorf("body decodes to %q, want %q", got, want)
+	}
+}
+
+// TestCompressHandlerEncodedResponse checks that responses the handler has already encoded pass through unchanged
+func TestCompressHandlerEncodedResponse(t *testing.T) {
+	body := []byte("already brotli-encoded bytes")
+	rec := httptest.NewRecorder()
+	serveCompressed(rec, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	})
+	if encoding := rec.Header().Get("Content-Encoding"); encoding != "br" {
+		t.Errorf("Content-Encoding is %q, want br", encoding)
+	}
+	if length := rec.Header().Get("Content-Length"); length != strconv.Itoa(len(body)) {
+		t.Errorf("Content-Length is %q, want %d", length, len(body))
+	}
+	if !bytes.Equal(rec.Body.Bytes(), body) {
+		t.Errorf("body is %q, want %q", rec.Body.Bytes(), body)
+	}
+}
+
+// TestCompressHandlerEncoderError checks that an encoder failure leaves the response without a Content-Encoding
+func TestCompressHandlerEncoderError(t *testing.T) {
+	errEncoder := errors.New("encoder unavailable")
+	failing := ContentEncoder{"gzip", func(io.Writer) (ResettableWriter, error) { return nil, errEncoder }}
+	rec := httptest.NewRecorder()
+	serveCompressed(rec, func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte("body")); !errors.Is(err, errEncoder) {
+			t.Errorf("Write returned %v, want %v", err, errEncoder)
+		}
+	}, failing)
+	if encoding := rec.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("Content-Encoding is %q after the encoder failed", encoding)
+	}
+}
+
+// getCompressed returns the response, decoded body and informational statuses of handler behind CompressHandler
+func getCompressed(t *testing.T, handler http.HandlerFunc) (*http.Response, []byte, []int) {
+	t.Helper()
+	srv := httptest.NewServer(CompressHandler(handler, ContentEncoder{"gzip", newStdlibGzipResponseWriter}))
+	t.Cleanup(srv.Close)
+
+	var informational []int
+	trace := &httptrace.ClientTrace{Got1xxResponse: func(code int, _ textproto.MIMEHeader) error {
+		informational = append(informational, code)
+		return nil
+	}}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		if body, err = gzip.NewReader(resp.Body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decoded, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, decoded, informational
+}
+
+// TestCompressHandlerEarlyHints checks that informational statuses pass through before the encoded response
+func TestCompressHandlerEarlyHints(t *testing.T) {
+	body := []byte("body after early hints")
+	resp, got, informational := getCompressed(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+	if len(informational) != 1 || informational[0] != http.StatusEarlyHints {
+		t.Errorf("informational statuses are %v, want [%d]", informational, http.StatusEarlyHints)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status is %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "gzip" {
+		t.Errorf("Content-Encoding is %q, want gzip", encoding)
+	}
+	if !bytes.Equal(got, body) {
+		t.Errorf("body decodes to %q, want %q", got, body)
+	}
+}
+
+// TestCompressHandlerResponseController checks that http.ResponseController works through the middleware
+func TestCompressHandlerResponseController(t *testing.T) {
+	_, got, _ := getCompressed(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(time.Minute)); err != nil {
+			t.Errorf("SetWriteDeadline: %v", err)
+		}
+		w.Write([]byte("body"))
+	})
+	if string(got) != "body" {
+		t.Errorf("body decodes to %q, want %q", got, "body")
+	}
+}
+
+// TestHTTPBody checks that JSON bodies are valid documents that fill most of the requested size
+func TestHTTPBody(t *testing.T) {
+	for _, size := range httpResponseSizes {
+		body, _ := httpBody(t, size, httpJSONData)
+		if !json.Valid(body) {
+			t.Errorf("size=%s: body is not valid JSON", sizeLabel(size))
+		}
+		if len(body) > size || len(body) < size/2 {
+			t.Errorf("size=%s: body is %d bytes", sizeLabel(size), len(body))
+		}
+	}
+}
+
+// TestNegotiateEncoding checks q-values, wildcards and server preference order
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{"zstd", "gzip"}
+	tests := []struct {
+		accept string
+		want   int
+	}{
+		{"", -1},
+		{"identity", -1},
+		{"gzip", 1},
+		{"gzip, zstd", 0},
+		{"GZIP;q=0.5, br", 1},
+		{"zstd;q=0, gzip", 1},
+		{"zstd; q=0.0, gzip;q=0", -1},
+		{"*", 0},
+		{"*;q=0, gzip", 1},
+		{"zstd;q=0, *", 1},
+		{"deflate, br", -1},
+	}
+	for _, tt := range tests {
+		if got := NegotiateEncoding(tt.accept, supported); got != tt.want {
+			t.Errorf("NegotiateEncoding(%q) = %d, want %d", tt.accept, got, tt.want)
+		}
+	}
+}
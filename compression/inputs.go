@@ -0,0 +1,198 @@
+package compression
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// InputsEnv names the directory or tarball of real files to benchmark alongside the generated data
+const InputsEnv = "COMPRESSION_INPUTS"
+
+// Input sampling parameters and the limit on all inputs together
+const (
+	InputSampleLimit = LargeSize
+	InputTotalLimit  = 1 << 30
+	inputSampleBlock = 64 << 10
+)
+
+// inputPrefix marks user-supplied data types so they cannot collide with generated ones
+const inputPrefix = "file:"
+
+// Input is one user-supplied file
+type Input struct {
+	Name       string // data type name, e.g. file:logs_build.log
+	Path       string // slash-separated path within the directory or archive
+	Data       []byte // file content, sampled down to the limit if it was larger
+	SourceSize int64  // size of the original file
+}
+
+// LoadInputs reads the files under a directory or in a tar archive, sampling files larger than limit
+func LoadInputs(path string, limit, totalLimit int) ([]Input, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &inputLoader{limit: limit, totalLimit: totalLimit}
+	if info.IsDir() {
+		err = l.loadDir(path)
+	} else {
+		err = l.loadTar(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	inputs := l.inputs
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("compression: no non-empty files found in %s", path)
+	}
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].Name < inputs[j].Name })
+	for i := 1; i < len(inputs); i++ {
+		if inputs[i].Name == inputs[i-1].Name {
+			return nil, fmt.Errorf("compression: %s and %s both map to %s; rename one of them",
+				inputs[i-1].Path, inputs[i].Path, inputs[i].Name)
+		}
+	}
+	return inputs, nil
+}
+
+// inputLoader collects inputs while enforcing the per-file and total limits
+type inputLoader struct {
+	limit      int
+	totalLimit int
+	total      int
+	inputs     []Input
+}
+
+func (l *inputLoader) loadDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		return l.add(filepath.ToSlash(rel), f, info.Size())
+	})
+}
+
+func (l *inputLoader) loadTar(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Accept .tar, .tar.gz and .tgz by content rather than by extension
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("compression: reading %s: %w", path, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := l.add(strings.TrimPrefix(hdr.Name, "./"), tr, hdr.Size); err != nil {
+			return err
+		}
+	}
+}
+
+// add reads a file of the given size unless it is empty, failing before the read if it would exceed the total limit
+func (l *inputLoader) add(path string, r io.Reader, size int64) error {
+	if size == 0 {
+		return nil
+	}
+	if n := int(min(size, int64(l.limit))); l.total+n > l.totalLimit {
+		return fmt.Errorf("compression: inputs exceed %d bytes in total at %s; use fewer or smaller files", l.totalLimit, path)
+	}
+	in, err := readInput(path, r, size, l.limit)
+	if err != nil {
+		return err
+	}
+	l.total += len(in.Data)
+	l.inputs = append(l.inputs, in)
+	return nil
+}
+
+// readInput reads a file of the given size, keeping a sample of at most limit bytes
+func readInput(path string, r io.Reader, size int64, limit int) (Input, error) {
+	var data []byte
+	var err error
+	if size <= int64(limit) {
+		data, err = io.ReadAll(r)
+	} else {
+		data, err = sampleInput(r, size, limit)
+	}
+	if err != nil {
+		return Input{}, fmt.Errorf("compression: reading %s: %w", path, err)
+	}
+	return Input{Name: inputName(path), Path: path, Data: data, SourceSize: size}, nil
+}
+
+// sampleInput reads limit bytes from a stream of size bytes as evenly spaced blocks, from the first to the last byte
+func sampleInput(r io.Reader, size int64, limit int) ([]byte, error) {
+	blocks := max(limit/inputSampleBlock, 1)
+	blockSize := limit / blocks
+	stride := size / int64(blocks)
+
+	data := make([]byte, 0, limit)
+	var pos int64
+	for i := range blocks {
+		n, start := blockSize, int64(i)*stride
+		if i == blocks-1 {
+			// The last block absorbs the remainder and ends at the end of the file
+			n = limit - len(data)
+			start = max(size-int64(n), pos)
+		}
+		if _, err := io.CopyN(io.Discard, r, start-pos); err != nil {
+			return nil, err
+		}
+		block := data[len(data) : len(data)+n]
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, err
+		}
+		data = data[:len(data)+n]
+		pos = start + int64(n)
+	}
+	return data, nil
+}
+
+// inputName turns a relative path into a data type name usable as a single sub-benchmark element
+func inputName(path string) string {
+	return inputPrefix + strings.NewReplacer("/", "_", " ", "_", "=", "_").Replace(path)
+}
@@ -0,0 +1,156 @@
+package compression
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// inputTestFiles are written to a directory or tarball by the input tests
+var inputTestFiles = map[string][]byte{
+	"build.log":         []byte("ok  \tgithub.com/example/pkg\t0.012s\n"),
+	"artifacts/app bin": bytes.Repeat([]byte{0xca, 0xfe}, 100),
+}
+
+// inputTestNames are the data type names of inputTestFiles, in load order
+var inputTestNames = []string{"file:artifacts_app_bin", "file:build.log"}
+
+// writeInputDir writes files to a new directory and returns it
+func writeInputDir(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// TestLoadInputsDir checks that a directory tree is loaded with one sanitized, sorted data type per file
+func TestLoadInputsDir(t *testing.T) {
+	checkInputs(t, writeInputDir(t, inputTestFiles))
+}
+
+// TestLoadInputsLimits checks empty files, name collisions and the total limit
+func TestLoadInputsLimits(t *testing.T) {
+	data := []byte("0123456789")
+
+	inputs, err := LoadInputs(writeInputDir(t, map[string][]byte{"empty": {}, "data": data}), InputSampleLimit, InputTotalLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 1 || inputs[0].Name != "file:data" {
+		t.Errorf("loaded %d inputs, want only file:data", len(inputs))
+	}
+	if _, err := LoadInputs(writeInputDir(t, map[string][]byte{"empty": {}}), InputSampleLimit, InputTotalLimit); err == nil {
+		t.Error("loaded a directory with only empty files")
+	}
+
+	if _, err := LoadInputs(writeInputDir(t, map[string][]byte{"a/b": data, "a_b": data}), InputSampleLimit, InputTotalLimit); err == nil {
+		t.Error("loaded a/b and a_b, which both map to file:a_b")
+	}
+
+	dir := writeInputDir(t, map[string][]byte{"1": data, "2": data, "3": data})
+	if _, err := LoadInputs(dir, InputSampleLimit, 3*len(data)); err != nil {
+		t.Errorf("inputs at the total limit: %v", err)
+	}
+	if _, err := LoadInputs(dir, InputSampleLimit, 3*len(data)-1); err == nil {
+		t.Error("loaded inputs over the total limit")
+	}
+	// Sampled files count at their sampled size
+	if _, err := LoadInputs(dir, 5, 15); err != nil {
+		t.Errorf("sampled inputs at the total limit: %v", err)
+	}
+}
+
+// TestLoadInputsTar checks that plain and gzip-compressed tarballs load the same inputs
+func TestLoadInputsTar(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		tw.WriteHeader(&tar.Header{Name: "./artifacts/", Typeflag: tar.TypeDir, Mode: 0o755})
+		for _, name := range []string{"build.log", "artifacts/app bin"} {
+			data := inputTestFiles[name]
+			if err := tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0o644, Size: int64(len(data))}); err != nil {
+				t.Fatal(err)
+			}
+			tw.Write(data)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		archive := buf.Bytes()
+		if compressed {
+			var gz bytes.Buffer
+			zw := gzip.NewWriter(&gz)
+			zw.Write(archive)
+			zw.Close()
+			archive = gz.Bytes()
+		}
+		path := filepath.Join(t.TempDir(), "inputs.tar")
+		if err := os.WriteFile(path, archive, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		checkInputs(t, path)
+	}
+}
+
+// checkInputs loads path and compares it with inputTestFiles
+func checkInputs(t *testing.T, path string) {
+	t.Helper()
+	inputs, err := LoadInputs(path, InputSampleLimit, InputTotalLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != len(inputTestNames) {
+		t.Fatalf("loaded %d inputs, want %d", len(inputs), len(inputTestNames))
+	}
+	for i, in := range inputs {
+		if in.Name != inputTestNames[i] {
+			t.Errorf("input %d is named %q, want %q", i, in.Name, inputTestNames[i])
+		}
+		if in.SourceSize != int64(len(in.Data)) {
+			t.Errorf("%s: source size %d for %d bytes of data", in.Name, in.SourceSize, len(in.Data))
+		}
+	}
+	if !bytes.Equal(inputs[1].Data, inputTestFiles["build.log"]) {
+		t.Error("build.log content does not match")
+	}
+}
+
+// TestSampleInput checks that large files are sampled reproducibly to exactly the limit
+func TestSampleInput(t *testing.T) {
+	const size, limit = 10<<20 + 12345, 1<<20 + 7
+	data := GenerateTestData(size, RandomData)
+
+	sample, err := sampleInput(bytes.NewReader(data), size, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sample) != limit {
+		t.Fatalf("sample is %d bytes, want %d", len(sample), limit)
+	}
+	if !bytes.Equal(sample[:inputSampleBlock], data[:inputSampleBlock]) {
+		t.Error("sample does not start with the beginning of the file")
+	}
+	if !bytes.Equal(sample[limit-inputSampleBlock:], data[size-inputSampleBlock:]) {
+		t.Error("sample does not end with the end of the file")
+	}
+
+	again, err := sampleInput(bytes.NewReader(data), size, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sample, again) {
+		t.Error("sampling is not reproducible")
+	}
+}
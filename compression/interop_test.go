@@ -0,0 +1,148 @@
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// interopFormat lists the codecs that write a wire format and the codecs that must read it
+type interopFormat struct {
+	name      string
+	producers []string
+	consumers []string
+	blocks    bool
+}
+
+// interopFormats pairs every codec with the other implementations of its format
+var interopFormats = []interopFormat{
+	{"gzip", []string{"gzip-klauspost", "gzip-stdlib"}, []string{"gzip-klauspost", "gzip-stdlib"}, false},
+	{"zstd", []string{"zstd-klauspost", "zstd-datadog"}, []string{"zstd-klauspost", "zstd-datadog"}, false},
+	{"flate", []string{"flate-klauspost", "flate-stdlib"}, []string{"flate-klauspost", "flate-stdlib"}, false},
+	{"zlib", []string{"zlib-klauspost", "zlib-stdlib"}, []string{"zlib-klauspost", "zlib-stdlib"}, false},
+	{"snappy", []string{"snappy-klauspost", "snappy-golang"}, []string{"snappy-klauspost", "snappy-golang", "s2-klauspost"}, true},
+}
+
+// interopInputSize is large enough to span several blocks of every format
+const interopInputSize = 256 << 10
+
+// interopBoundaries are the internal window and block sizes of the formats under test
+var interopBoundaries = []int{
+	32 << 10,  // DEFLATE window
+	64 << 10,  // Snappy block and frame chunk
+	128 << 10, // zstd maximum block
+	1 << 20,   // S2 stream block
+}
+
+// interopInput is a named payload fed to every producer/consumer pair
+type interopInput struct {
+	name string
+	data []byte
+}
+
+// interopInputs returns every GenerateTestData type plus empty, single-byte and boundary-sized inputs
+func interopInputs(t *testing.T) []interopInput {
+	var inputs []interopInput
+	for _, dataType := range benchmarkDataTypes {
+		inputs = append(inputs, interopInput{"data=" + dataType, testData(t, interopInputSize, dataType)})
+	}
+
+	inputs = append(inputs,
+		interopInput{"edge=empty", []byte{}},
+		interopInput{"edge=1B", []byte{'x'}},
+	)
+
+	// Boundary inputs are prefixes of one text buffer so they share content
+	largest := interopBoundaries[len(interopBoundaries)-1] + 1
+	text := testData(t, largest, TextData)
+	for _, boundary := range interopBoundaries {
+		for _, size := range []int{boundary - 1, boundary, boundary + 1} {
+			inputs = append(inputs, interopInput{fmt.Sprintf("edge=%dB", size), text[:size]})
+		}
+	}
+	return inputs
+}
+
+// TestCompressionInterop checks that every consumer of a format decodes what every producer wrote
+func TestCompressionInterop(t *testing.T) {
+	inputs := interopInputs(t)
+
+	for _, format := range interopFormats {
+		for _, producerName := range format.producers {
+			for _, consumerName := range format.consumers {
+				pair := producerName + "->" + consumerName
+				t.Run(pair, func(t *testing.T) {
+					producer := lookupInteropCodec(t, producerName)
+					consumer := lookupInteropCodec(t, consumerName)
+					t.Parallel()
+					for _, level := range producer.Levels() {
+						t.Run(fmt.Sprintf("level=%d", level), func(t *testing.T) {
+							for _, in := range inputs {
+								t.Run(in.name, func(t *testing.T) {
+									checkInterop(t, producer, consumer, level, in.data, format.blocks)
+								})
+							}
+						})
+					}
+				})
+			}
+		}
+	}
+}
+
+// lookupInteropCodec returns a registered codec or skips the test if it is unavailable in this build
+func lookupInteropCodec(t *testing.T, name string) Codec {
+	t.Helper()
+	c, ok := LookupCodec(name)
+	if reason, skip := SkippedCodecs()[name]; !ok && skip {
+		t.Skipf("codec %q skipped: %s", name, reason)
+	}
+	if !ok {
+		t.Fatalf("codec %q is not registered", name)
+	}
+	return c
+}
+
+// checkInterop checks that consumer recovers data compressed by producer through each API
+func checkInterop(t *testing.T, producer, consumer Codec, level int, data []byte, blocks bool) {
+	t.Helper()
+
+	oneShot, err := producer.Compress(nil, data, level)
+	if err != nil {
+		t.Fatalf("%s one-shot compress: %v", producer.Name(), err)
+	}
+	stream, err := compressStream(producer, nil, data, level)
+	if err != nil {
+		t.Fatalf("%s stream compress: %v", producer.Name(), err)
+	}
+
+	encoded := []struct {
+		api        string
+		compressed []byte
+	}{
+		{"one-shot", oneShot},
+		{"stream", stream},
+	}
+	decoders := []struct {
+		api        string
+		decompress func(dst, src []byte) ([]byte, error)
+	}{
+		{"one-shot", consumer.Decompress},
+		{"stream", func(dst, src []byte) ([]byte, error) { return decompressStream(consumer, dst, src) }},
+	}
+
+	for _, enc := range encoded {
+		for _, dec := range decoders {
+			if blocks && enc.api != dec.api {
+				continue
+			}
+			got, err := dec.decompress(nil, enc.compressed)
+			if err != nil {
+				t.Errorf("%s %s output, %s %s decompress: %v", producer.Name(), enc.api, consumer.Name(), dec.api, err)
+			} else if !bytes.Equal(data, got) {
+				t.Errorf("%s %s output, %s %s decompress: got %d bytes, want %d matching bytes",
+					producer.Name(), enc.api, consumer.Name(), dec.api, len(got), len(data))
+			}
+		}
+	}
+}
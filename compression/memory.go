@@ -0,0 +1,134 @@
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memorySampleInterval is how often live heap and RSS are polled while an operation runs
+const memorySampleInterval = 500 * time.Microsecond
+
+// memoryMinSamples is how many polls an operation must span for its sampled peaks to be reported
+const memoryMinSamples = 3
+
+// Metrics read by measureMemory
+const (
+	metricHeapObjects = "/memory/classes/heap/objects:bytes"
+	metricHeapAllocs  = "/gc/heap/allocs:bytes"
+)
+
+// memoryStats is the memory high-water mark of one measured operation
+type memoryStats struct {
+	peakHeap  uint64 // highest live Go heap above the starting point
+	allocated uint64 // total Go heap allocated, an upper bound on peakHeap that sampling cannot miss
+	peakRSS   uint64 // highest resident set size above the starting point, including cgo allocations
+	rssKnown  bool   // false where /proc/self/status is unavailable
+	hwmReset  bool   // peakRSS includes the kernel's high-water mark, so it does not depend on sampling
+	samples   int    // polls taken while the operation ran
+}
+
+// measureMemory runs fn while polling the Go heap and the process RSS
+func measureMemory(fn func()) memoryStats {
+	samples := []metrics.Sample{{Name: metricHeapObjects}, {Name: metricHeapAllocs}}
+	debug.FreeOSMemory()
+	metrics.Read(samples)
+	baseHeap, baseAllocs := samples[0].Value.Uint64(), samples[1].Value.Uint64()
+	hwmReset := resetPeakRSS()
+	baseRSS, _, rssKnown := readRSS()
+
+	var peakHeap, peakRSS uint64
+	var polls int
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s := []metrics.Sample{{Name: metricHeapObjects}}
+		ticker := time.NewTicker(memorySampleInterval)
+		defer ticker.Stop()
+		for {
+			polls++
+			metrics.Read(s)
+			peakHeap = max(peakHeap, above(s[0].Value.Uint64(), baseHeap))
+			if rssKnown {
+				if rss, _, ok := readRSS(); ok {
+					peakRSS = max(peakRSS, above(rss, baseRSS))
+				}
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	fn()
+	close(stop)
+	wg.Wait()
+
+	metrics.Read(samples)
+	if _, hwm, ok := readRSS(); ok && hwmReset {
+		peakRSS = max(peakRSS, above(hwm, baseRSS))
+	}
+	return memoryStats{
+		peakHeap:  peakHeap,
+		allocated: samples[1].Value.Uint64() - baseAllocs,
+		peakRSS:   peakRSS,
+		rssKnown:  rssKnown,
+		hwmReset:  hwmReset && rssKnown,
+		samples:   polls,
+	}
+}
+
+// above returns how far v exceeds base, or 0
+func above(v, base uint64) uint64 {
+	if v > base {
+		return v - base
+	}
+	return 0
+}
+
+// readRSS returns the current resident set size and its high-water mark in bytes from /proc/self/status
+func readRSS() (rss, hwm uint64, ok bool) {
+	status, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0, 0, false
+	}
+	var foundRSS, foundHWM bool
+	sc := bufio.NewScanner(bytes.NewReader(status))
+	for sc.Scan() {
+		key, value, found := bytes.Cut(sc.Bytes(), []byte(":"))
+		if !found {
+			continue
+		}
+		switch string(key) {
+		case "VmRSS":
+			rss, foundRSS = parseKB(value)
+		case "VmHWM":
+			hwm, foundHWM = parseKB(value)
+		}
+	}
+	return rss, hwm, foundRSS && foundHWM
+}
+
+// parseKB parses a /proc/self/status value such as "  123456 kB" into bytes
+func parseKB(value []byte) (uint64, bool) {
+	num, _, _ := bytes.Cut(bytes.TrimSpace(value), []byte(" "))
+	kb, err := strconv.ParseUint(string(num), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return kb << 10, true
+}
+
+// resetPeakRSS resets the kernel's RSS high-water mark (VmHWM), reporting whether it could
+func resetPeakRSS() bool {
+	return os.WriteFile("/proc/self/clear_refs", []byte("5"), 0) == nil
+}
@@ -0,0 +1,389 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"testing"
+
+	kgzip "github.com/klauspost/compress/gzip"
+	klauspost "github.com/klauspost/compress/zstd"
+)
+
+// Inputs and levels for the multi-threaded benchmarks
+var parallelSizes = []int{MediumSize, LargeSize}
+
+const (
+	parallelZstdLevel = 3
+	parallelGzipLevel = 3
+)
+
+// parallelGzipBlockSize is the amount of input compressed into each gzip member
+const parallelGzipBlockSize = 1 << 20
+
+// threadCounts returns powers of two from 1 up to GOMAXPROCS, always ending with GOMAXPROCS
+func threadCounts() []int {
+	procs := runtime.GOMAXPROCS(0)
+	var counts []int
+	for n := 1; n < procs; n *= 2 {
+		counts = append(counts, n)
+	}
+	return append(counts, procs)
+}
+
+// parallelWriter creates a streaming compressor that uses up to threads goroutines or C threads
+type parallelWriter struct {
+	name string
+	new  func(w io.Writer, threads int) (io.WriteCloser, error)
+}
+
+// parallelWriters lists the writers swept; DataDog is added in cgo builds
+var parallelWriters = []parallelWriter{
+	{"zstd-klauspost", newKlauspostZstdParallelWriter},
+	{"gzip-klauspost-blocks", newParallelGzipWriter},
+}
+
+func newKlauspostZstdParallelWriter(w io.Writer, threads int) (io.WriteCloser, error) {
+	return klauspost.NewWriter(w,
+		klauspost.WithEncoderLevel(klauspost.EncoderLevelFromZstd(parallelZstdLevel)),
+		klauspost.WithEncoderConcurrency(threads),
+	)
+}
+
+func newParallelGzipWriter(w io.Writer, threads int) (io.WriteCloser, error) {
+	return newBlockGzipWriter(w, parallelGzipLevel, threads, parallelGzipBlockSize)
+}
+
+// blockGzipWriter compresses fixed-size blocks as independent gzip members on up to threads goroutines
+type blockGzipWriter struct {
+	w         io.Writer
+	level     int
+	blockSize int
+	buf       []byte
+	sem       chan struct{}
+	order     chan chan gzipMember
+	done      chan error
+	pool      sync.Pool
+
+	mu  sync.Mutex
+	err error
+}
+
+// gzipMember is the compressed form of one block, or the error that stopped it
+type gzipMember struct {
+	data []byte
+	err  error
+}
+
+func newBlockGzipWriter(w io.Writer, level, threads, blockSize int) (*blockGzipWriter, error) {
+	// Validate the level up front so a bad level fails here rather than on the first block
+	if _, err := kgzip.NewWriterLevel(io.Discard, level); err != nil {
+		return nil, err
+	}
+	p := &blockGzipWriter{
+		w:         w,
+		level:     level,
+		blockSize: blockSize,
+		buf:       make([]byte, 0, blockSize),
+		sem:       make(chan struct{}, threads),
+		order:     make(chan chan gzipMember, threads),
+		done:      make(chan error, 1),
+	}
+	go p.writeMembers()
+	return p, nil
+}
+
+func (p *blockGzipWriter) Write(data []byte) (int, error) {
+	written := 0
+	for len(data) > 0 {
+		if err := p.firstErr(); err != nil {
+			return written, err
+		}
+		n := min(p.blockSize-len(p.buf), len(data))
+		p.buf = append(p.buf, data[:n]...)
+		data = data[n:]
+		written += n
+		if len(p.buf) == p.blockSize {
+			p.dispatch()
+		}
+	}
+	return written, p.firstErr()
+}
+
+// Close compresses any buffered input, waits for all members to be written and returns the first error
+func (p *blockGzipWriter) Close() error {
+	if len(p.buf) > 0 {
+		p.dispatch()
+	}
+	close(p.order)
+	return <-p.done
+}
+
+// dispatch hands the buffered block to a compression goroutine, waiting while threads blocks are in flight
+func (p *blockGzipWriter) dispatch() {
+	block := p.buf
+	p.buf = make([]byte, 0, p.blockSize)
+
+	member := make(chan gzipMember, 1)
+	p.sem <- struct{}{}
+	p.order <- member
+	go func() {
+		defer func() { <-p.sem }()
+		data, err := p.compressBlock(block)
+		member <- gzipMember{data, err}
+	}()
+}
+
+// compressBlock compresses one block into a complete gzip member
+func (p *blockGzipWriter) compressBlock(block []byte) ([]byte, error) {
+	var out bytes.Buffer
+	zw, _ := p.pool.Get().(*kgzip.Writer)
+	if zw == nil {
+		var err error
+		if zw, err = kgzip.NewWriterLevel(&out, p.level); err != nil {
+			return nil, err
+		}
+	} else {
+		zw.Reset(&out)
+	}
+	if _, err := zw.Write(block); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	p.pool.Put(zw)
+	return out.Bytes(), nil
+}
+
+// writeMembers writes compressed members in the order their blocks were dispatched, stopping at the first error
+func (p *blockGzipWriter) writeMembers() {
+	for member := range p.order {
+		m := <-member
+		err := m.err
+		if err == nil && p.firstErr() == nil {
+			_, err = p.w.Write(m.data)
+		}
+		if err != nil {
+			p.setErr(err)
+		}
+	}
+	p.done <- p.firstErr()
+}
+
+func (p *blockGzipWriter) firstErr() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// setErr records err unless an earlier error was already recorded
+func (p *blockGzipWriter) setErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// BenchmarkParallelCompression sweeps encoder concurrency from 1 to GOMAXPROCS
+func BenchmarkParallelCompression(b *testing.B) {
+	for _, pw := range parallelWriters {
+		b.Run("codec="+pw.name, func(b *testing.B) {
+			runParallelMatrix(b, func(b *testing.B, data []byte, threads int) {
+				benchmarkParallelCompress(b, pw, data, threads)
+			})
+		})
+	}
+}
+
+// BenchmarkParallelDecompression sweeps klauspost zstd decoder concurrency from 1 to GOMAXPROCS
+func BenchmarkParallelDecompression(b *testing.B) {
+	b.Run("codec="+klauspostZstd.Name(), func(b *testing.B) {
+		runParallelMatrix(b, benchmarkParallelDecompress)
+	})
+}
+
+// runParallelMatrix runs fn for every parallel size, data type and thread count
+func runParallelMatrix(b *testing.B, fn func(b *testing.B, data []byte, threads int)) {
+	for _, size := range parallelSizes {
+		b.Run("size="+sizeLabel(size), func(b *testing.B) {
+			for _, dataType := range benchmarkDataTypes {
+				b.Run("data="+dataType, func(b *testing.B) {
+					data := testData(b, size, dataType)
+					for _, threads := range threadCounts() {
+						b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+							fn(b, data, threads)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+func benchmarkParallelCompress(b *testing.B, pw parallelWriter, data []byte, threads int) {
+	// Verify the stream decodes before timing it
+	var buf bytes.Buffer
+	if err := writeParallel(pw, &buf, data, threads); err != nil {
+		b.Fatal(err)
+	}
+	if err := verifyParallelOutput(pw.name, buf.Bytes(), data); err != nil {
+		b.Fatal(err)
+	}
+	compressedSize := buf.Len()
+
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := writeParallel(pw, &buf, data, threads); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	reportPerCore(b, len(data), threads)
+	b.ReportMetric(float64(len(data))/float64(compressedSize), "ratio")
+}
+
+func benchmarkParallelDecompress(b *testing.B, data []byte, threads int) {
+	compressed, err := klauspostZstd.Compress(nil, data, parallelZstdLevel)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	r := bytes.NewReader(compressed)
+	dec, err := klauspost.NewReader(r, klauspost.WithDecoderConcurrency(threads))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer dec.Close()
+
+	// Verify decompression
+	var out bytes.Buffer
+	if _, err := out.ReadFrom(dec); err != nil {
+		b.Fatal(err)
+	}
+	if !bytes.Equal(data, out.Bytes()) {
+		b.Fatal("Decompressed data does not match original")
+	}
+
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		r.Reset(compressed)
+		if err := dec.Reset(r); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(io.Discard, dec); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	reportPerCore(b, len(data), threads)
+}
+
+// writeParallel compresses data to w with a writer using the given number of threads
+func writeParallel(pw parallelWriter, w io.Writer, data []byte, threads int) error {
+	zw, err := pw.new(w, threads)
+	if err != nil {
+		return err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// verifyParallelOutput decodes a parallel writer's output with a single-threaded reader of the same format
+func verifyParallelOutput(name string, compressed, data []byte) error {
+	var got []byte
+	var err error
+	switch name {
+	case "gzip-klauspost-blocks":
+		// Read with the standard library to show the multi-member stream is plain gzip
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(compressed)); err == nil {
+			got, err = io.ReadAll(zr)
+		}
+	default:
+		got, err = klauspostZstd.Decompress(nil, compressed)
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data, got) {
+		return fmt.Errorf("%s output does not decompress to the original data", name)
+	}
+	return nil
+}
+
+// reportPerCore reports throughput divided by the thread count, so a flat MB/s/core means linear scaling
+func reportPerCore(b *testing.B, size, threads int) {
+	mbPerSec := float64(size) * float64(b.N) / b.Elapsed().Seconds() / 1e6
+	b.ReportMetric(mbPerSec/float64(threads), "MB/s/core")
+}
+
+// failingWriter accepts limit bytes and then fails every write
+type failingWriter struct {
+	limit int
+}
+
+var errFailingWriter = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errFailingWriter
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+// TestBlockGzipWriter checks that block-parallel output is plain gzip and that write errors surface
+func TestBlockGzipWriter(t *testing.T) {
+	const blockSize = 4 << 10
+	data := testData(t, SmallSize, TextData)
+	for _, threads := range []int{1, 4} {
+		var buf bytes.Buffer
+		zw, err := newBlockGzipWriter(&buf, parallelGzipLevel, threads, blockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := zw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyParallelOutput("gzip-klauspost-blocks", buf.Bytes(), data); err != nil {
+			t.Errorf("threads=%d: %v", threads, err)
+		}
+
+		zw, err = newBlockGzipWriter(&failingWriter{limit: 100}, parallelGzipLevel, threads, blockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var writeErr error
+		for off := 0; off < len(data) && writeErr == nil; off += blockSize {
+			_, writeErr = zw.Write(data[off : off+blockSize])
+		}
+		if !errors.Is(writeErr, errFailingWriter) {
+			t.Errorf("threads=%d: Write after a failed member returned %v, want %v", threads, writeErr, errFailingWriter)
+		}
+		if err := zw.Close(); !errors.Is(err, errFailingWriter) {
+			t.Errorf("threads=%d: Close returned %v, want %v", threads, err, errFailingWriter)
+		}
+	}
+
+	if _, err := newBlockGzipWriter(io.Discard, 42, 1, blockSize); err == nil {
+		t.Error("accepted gzip level 42")
+	}
+}
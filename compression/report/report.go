@@ -0,0 +1,276 @@
+// Package report combines compression benchmark results into a speed/ratio table with Pareto-optimal rows marked.
+package report
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Benchmark name suffixes whose results are paired into one row
+const (
+	compressSuffix   = "Compression"
+	decompressSuffix = "Decompression"
+)
+
+// inputKeys are the sub-benchmark keys that describe the input rather than the configuration
+var inputKeys = map[string]bool{"size": true, "data": true, "random": true}
+
+// Row is one configuration's combined results, averaged over repeated runs
+type Row struct {
+	Family string // benchmark family, e.g. "Compression" or "Entropy" for BenchmarkEntropyCompression
+	Input  string // input keys, e.g. "size=1MB/data=text"
+	Config string // remaining keys, e.g. "codec=zstd-klauspost/level=3"
+
+	CompressMBps   float64 // 0 if not measured
+	DecompressMBps float64 // 0 if not measured
+	Ratio          float64 // 0 if not measured
+
+	Pareto bool // no other configuration on the same input is at least as good on every measured metric and better on one
+}
+
+// metrics returns the row's measurements in a fixed order; 0 means not measured
+func (r *Row) metrics() [3]float64 {
+	return [3]float64{r.CompressMBps, r.DecompressMBps, r.Ratio}
+}
+
+// rowKey identifies a configuration on an input
+type rowKey struct{ family, input, config string }
+
+// sample accumulates one metric over repeated runs
+type sample struct {
+	sum float64
+	n   int
+}
+
+func (s *sample) add(v float64) {
+	s.sum += v
+	s.n++
+}
+
+func (s sample) mean() float64 {
+	if s.n == 0 {
+		return 0
+	}
+	return s.sum / float64(s.n)
+}
+
+// Parse reads go test -bench output and returns one row per configuration
+func Parse(r io.Reader) ([]Row, error) {
+	type acc struct{ compress, decompress, ratio sample }
+	accs := make(map[rowKey]*acc)
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for sc.Scan() {
+		key, values, ok := parseLine(sc.Text())
+		if !ok {
+			continue
+		}
+		a := accs[key.rowKey]
+		if a == nil {
+			a = &acc{}
+			accs[key.rowKey] = a
+		}
+		if mbps, ok := values["MB/s"]; ok {
+			if key.decompress {
+				a.decompress.add(mbps)
+			} else {
+				a.compress.add(mbps)
+			}
+		}
+		if ratio, ok := values["ratio"]; ok && !key.decompress {
+			a.ratio.add(ratio)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, 0, len(accs))
+	for k, a := range accs {
+		rows = append(rows, Row{
+			Family:         k.family,
+			Input:          k.input,
+			Config:         k.config,
+			CompressMBps:   a.compress.mean(),
+			DecompressMBps: a.decompress.mean(),
+			Ratio:          a.ratio.mean(),
+		})
+	}
+	slices.SortFunc(rows, func(a, b Row) int {
+		return cmp.Or(cmp.Compare(a.Family, b.Family), cmp.Compare(a.Input, b.Input), compareConfig(a.Config, b.Config))
+	})
+	markPareto(rows)
+	return rows, nil
+}
+
+// lineKey is where a result line belongs
+type lineKey struct {
+	rowKey
+	decompress bool
+}
+
+// parseLine parses a result line such as
+//
+//	BenchmarkCompression/codec=gzip-std/size=1MB/data=text/level=1-8  10  1234 ns/op  85.0 MB/s  3.10 ratio
+func parseLine(line string) (lineKey, map[string]float64, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+		return lineKey{}, nil, false
+	}
+	if _, err := strconv.Atoi(fields[1]); err != nil {
+		return lineKey{}, nil, false
+	}
+
+	name, subs, _ := strings.Cut(trimProcs(fields[0]), "/")
+	name = strings.TrimPrefix(name, "Benchmark")
+	var key lineKey
+	switch {
+	case strings.HasSuffix(name, decompressSuffix):
+		key.family, key.decompress = strings.TrimSuffix(name, decompressSuffix), true
+	case strings.HasSuffix(name, compressSuffix):
+		key.family = strings.TrimSuffix(name, compressSuffix)
+	default:
+		return lineKey{}, nil, false
+	}
+	if key.family == "" {
+		key.family = compressSuffix
+	}
+
+	var input, config []string
+	for _, sub := range strings.Split(subs, "/") {
+		k, _, _ := strings.Cut(sub, "=")
+		if inputKeys[k] {
+			input = append(input, sub)
+		} else if sub != "" {
+			config = append(config, sub)
+		}
+	}
+	key.input, key.config = strings.Join(input, "/"), strings.Join(config, "/")
+
+	values := make(map[string]float64)
+	for i := 2; i+1 < len(fields); i += 2 {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return lineKey{}, nil, false
+		}
+		values[fields[i+1]] = v
+	}
+	return key, values, true
+}
+
+// trimProcs removes the -GOMAXPROCS suffix go test appends to benchmark names
+func trimProcs(name string) string {
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 {
+		return name
+	}
+	if _, err := strconv.Atoi(name[i+1:]); err != nil {
+		return name
+	}
+	return name[:i]
+}
+
+// compareConfig orders configurations element by element, comparing numeric values such as levels as numbers
+func compareConfig(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := range min(len(as), len(bs)) {
+		ak, av, _ := strings.Cut(as[i], "=")
+		bk, bv, _ := strings.Cut(bs[i], "=")
+		if c := cmp.Compare(ak, bk); c != 0 {
+			return c
+		}
+		an, aerr := strconv.ParseFloat(av, 64)
+		bn, berr := strconv.ParseFloat(bv, 64)
+		if aerr == nil && berr == nil {
+			if c := cmp.Compare(an, bn); c != 0 {
+				return c
+			}
+		} else if c := cmp.Compare(av, bv); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// markPareto sets Pareto on every row that no other row of the same family and input dominates
+func markPareto(rows []Row) {
+	for i := range rows {
+		rows[i].Pareto = true
+		for j := range rows {
+			if i != j && rows[j].Family == rows[i].Family && rows[j].Input == rows[i].Input && dominates(&rows[j], &rows[i]) {
+				rows[i].Pareto = false
+				break
+			}
+		}
+	}
+}
+
+// dominates reports whether a is at least as good as b on every metric b has and strictly better on one
+func dominates(a, b *Row) bool {
+	am, bm := a.metrics(), b.metrics()
+	better := false
+	for i := range am {
+		if bm[i] == 0 {
+			continue
+		}
+		if am[i] == 0 || am[i] < bm[i] {
+			return false
+		}
+		if am[i] > bm[i] {
+			better = true
+		}
+	}
+	return better
+}
+
+// WriteMarkdown writes one table per family and input, with Pareto-optimal configurations marked
+func WriteMarkdown(w io.Writer, rows []Row) error {
+	bw := bufio.NewWriter(w)
+	for i, r := range rows {
+		if i == 0 || r.Family != rows[i-1].Family || r.Input != rows[i-1].Input {
+			if i > 0 {
+				bw.WriteString("\n")
+			}
+			fmt.Fprintf(bw, "### %s %s\n\n", r.Family, r.Input)
+			bw.WriteString("| Configuration | Compress MB/s | Decompress MB/s | Ratio | Pareto |\n")
+			bw.WriteString("| --- | ---: | ---: | ---: | :---: |\n")
+		}
+		pareto := ""
+		if r.Pareto {
+			pareto = "✓"
+		}
+		fmt.Fprintf(bw, "| %s | %s | %s | %s | %s |\n", r.Config,
+			formatMetric(r.CompressMBps, 1), formatMetric(r.DecompressMBps, 1), formatMetric(r.Ratio, 3), pareto)
+	}
+	return bw.Flush()
+}
+
+// WriteCSV writes every row with a header line; unmeasured metrics are left empty
+func WriteCSV(w io.Writer, rows []Row) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"family", "input", "config", "compress_mbps", "decompress_mbps", "ratio", "pareto"})
+	for _, r := range rows {
+		cw.Write([]string{
+			r.Family, r.Input, r.Config,
+			formatMetric(r.CompressMBps, 2), formatMetric(r.DecompressMBps, 2), formatMetric(r.Ratio, 4),
+			strconv.FormatBool(r.Pareto),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatMetric formats a measurement, or an empty string if it was not measured
+func formatMetric(v float64, prec int) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', prec, 64)
+}
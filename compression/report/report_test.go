@@ -0,0 +1,97 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// benchOutput is trimmed go test -bench output with two runs of each result
+const benchOutput = `goos: linux
+goarch: amd64
+pkg: go-benchmarks/compression
+corpus-text-1MB-seed42: sha256:cdf9ee4f31eef2798109eb90533139aeeb52f5d1e503c0d4d3180f91ba53798a
+BenchmarkCompression/codec=fast/size=1MB/data=text/level=1-8     10  1000 ns/op  300.00 MB/s  1048576 peak-heap-B  2.000 ratio
+BenchmarkCompression/codec=fast/size=1MB/data=text/level=1-8     10  1000 ns/op  100.00 MB/s  1048576 peak-heap-B  2.000 ratio
+--- BENCH: BenchmarkCompression/codec=fast/size=1MB/data=text/level=1
+    codec_test.go:67: fast level 1 runs as default
+BenchmarkCompression/codec=slow/size=1MB/data=text/level=9-8     10  1000 ns/op   50.00 MB/s  3.000 ratio
+BenchmarkCompression/codec=worse/size=1MB/data=text/level=10-8   10  1000 ns/op   40.00 MB/s  2.500 ratio
+BenchmarkDecompression/codec=fast/size=1MB/data=text/level=1-8   10  1000 ns/op  500.00 MB/s
+BenchmarkDecompression/codec=slow/size=1MB/data=text/level=9-8   10  1000 ns/op  400.00 MB/s
+BenchmarkDecompression/codec=worse/size=1MB/data=text/level=10-8 10  1000 ns/op  400.00 MB/s
+BenchmarkEntropyCompression/codec=fast/random=50/level=1         10  1000 ns/op   80.00 MB/s  1.700 ratio
+BenchmarkDecompressionBomb/codec=fast/decoder=limit-reader-8     10  1000 ns/op   16.00 MB/s  1000 bomb-ratio
+PASS
+ok  	go-benchmarks/compression	12.345s
+`
+
+// TestParse checks that compression and decompression results are paired, averaged over runs and marked
+func TestParse(t *testing.T) {
+	rows, err := Parse(strings.NewReader(benchOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{"Compression", "size=1MB/data=text", "codec=fast/level=1", 200, 500, 2, true},
+		{"Compression", "size=1MB/data=text", "codec=slow/level=9", 50, 400, 3, true},
+		{"Compression", "size=1MB/data=text", "codec=worse/level=10", 40, 400, 2.5, false},
+		{"Entropy", "random=50", "codec=fast/level=1", 80, 0, 1.7, true},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d is %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+}
+
+// TestDominatesMissingMetric checks that a configuration without a metric never dominates one with it
+func TestDominatesMissingMetric(t *testing.T) {
+	measured := &Row{CompressMBps: 10, DecompressMBps: 10, Ratio: 1}
+	partial := &Row{CompressMBps: 100, Ratio: 5}
+	if dominates(partial, measured) {
+		t.Error("a row without decompression results dominates one with them")
+	}
+	if !dominates(measured, &Row{CompressMBps: 5, Ratio: 1}) {
+		t.Error("a row that is better on every shared metric does not dominate")
+	}
+}
+
+// TestWrite checks the Markdown and CSV formats
+func TestWrite(t *testing.T) {
+	rows, err := Parse(strings.NewReader(benchOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var md bytes.Buffer
+	if err := WriteMarkdown(&md, rows); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"### Compression size=1MB/data=text\n",
+		"| codec=fast/level=1 | 200.0 | 500.0 | 2.000 | ✓ |\n",
+		"| codec=worse/level=10 | 40.0 | 400.0 | 2.500 |  |\n",
+		"### Entropy random=50\n",
+		"| codec=fast/level=1 | 80.0 |  | 1.700 | ✓ |\n",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Markdown output does not contain %q:\n%s", want, md.String())
+		}
+	}
+
+	var csv bytes.Buffer
+	if err := WriteCSV(&csv, rows); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != len(rows)+1 {
+		t.Fatalf("CSV has %d lines, want %d", len(lines), len(rows)+1)
+	}
+	if want := "Compression,size=1MB/data=text,codec=slow/level=9,50.00,400.00,3.0000,true"; lines[2] != want {
+		t.Errorf("CSV line is %q, want %q", lines[2], want)
+	}
+}
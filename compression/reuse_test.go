@@ -0,0 +1,207 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+)
+
+// reuseSizes range from payloads where encoder setup dominates to ones where it is noise
+var reuseSizes = []int{4 << 10, 64 << 10, SmallSize, MediumSize}
+
+// Lifecycles compared by the reuse benchmarks
+const (
+	reuseFresh = "fresh" // allocate a new encoder or decoder for every operation
+	reuseReset = "reset" // keep one encoder or decoder and Reset it for every operation
+	reusePool  = "pool"  // take encoders and decoders from a sync.Pool and Reset them
+)
+
+var reuseModes = []string{reuseFresh, reuseReset, reusePool}
+
+// BenchmarkEncoderReuse compares fresh, Reset-reused and pooled streaming encoders for every codec
+func BenchmarkEncoderReuse(b *testing.B) {
+	runCodecMatrix(b, reuseSizes, func(b *testing.B, c Codec, size int, dataType string, level int) {
+		for _, mode := range reuseModes {
+			b.Run("mode="+mode, func(b *testing.B) {
+				benchmarkEncoderReuse(b, c, size, dataType, level, mode)
+			})
+		}
+	})
+}
+
+// BenchmarkDecoderReuse compares fresh, Reset-reused and pooled streaming decoders for every codec
+func BenchmarkDecoderReuse(b *testing.B) {
+	runCodecMatrix(b, reuseSizes, func(b *testing.B, c Codec, size int, dataType string, level int) {
+		for _, mode := range reuseModes {
+			b.Run("mode="+mode, func(b *testing.B) {
+				benchmarkDecoderReuse(b, c, size, dataType, level, mode)
+			})
+		}
+	})
+}
+
+func benchmarkEncoderReuse(b *testing.B, c Codec, size int, dataType string, level int, mode string) {
+	data := testData(b, size, dataType)
+	src := newWriterSource(b, c, level, mode)
+
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.SetBytes(int64(size))
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		zw, err := src.get(&buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := zw.Write(data); err != nil {
+			b.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			b.Fatal(err)
+		}
+		src.put(zw)
+	}
+}
+
+func benchmarkDecoderReuse(b *testing.B, c Codec, size int, dataType string, level int, mode string) {
+	data := testData(b, size, dataType)
+	compressed, err := compressStream(c, nil, data, level)
+	if err != nil {
+		b.Fatal(err)
+	}
+	src := newReaderSource(b, c, mode)
+	defer src.close()
+
+	// Verify decompression through the same lifecycle
+	var buf bytes.Buffer
+	zr, err := src.get(bytes.NewReader(compressed))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := buf.ReadFrom(zr); err != nil {
+		b.Fatal(err)
+	}
+	src.put(zr)
+	if !bytes.Equal(data, buf.Bytes()) {
+		b.Fatal("Decompressed data does not match original")
+	}
+
+	r := bytes.NewReader(compressed)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.SetBytes(int64(size))
+	for i := 0; i < b.N; i++ {
+		r.Reset(compressed)
+		zr, err := src.get(r)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(io.Discard, zr); err != nil {
+			b.Fatal(err)
+		}
+		src.put(zr)
+	}
+}
+
+// writerSource hands out streaming encoders according to a reuse mode
+type writerSource struct {
+	c      Codec
+	rs     Resetter
+	level  int
+	mode   string
+	reused io.WriteCloser
+	pool   sync.Pool
+}
+
+// newWriterSource skips the benchmark when the mode needs Reset and the codec cannot provide it
+func newWriterSource(b *testing.B, c Codec, level int, mode string) *writerSource {
+	rs, ok := c.(Resetter)
+	if mode != reuseFresh && !ok {
+		b.Skipf("%s encoders cannot be reset", c.Name())
+	}
+	return &writerSource{c: c, rs: rs, level: level, mode: mode}
+}
+
+func (s *writerSource) get(w io.Writer) (io.WriteCloser, error) {
+	var zw io.WriteCloser
+	switch s.mode {
+	case reuseReset:
+		zw = s.reused
+	case reusePool:
+		zw, _ = s.pool.Get().(io.WriteCloser)
+	}
+	if zw == nil {
+		return s.c.NewWriter(w, s.level)
+	}
+	return zw, s.rs.ResetWriter(zw, w)
+}
+
+// put returns a closed encoder for reuse
+func (s *writerSource) put(zw io.WriteCloser) {
+	switch s.mode {
+	case reuseReset:
+		s.reused = zw
+	case reusePool:
+		s.pool.Put(zw)
+	}
+}
+
+// readerSource hands out streaming decoders according to a reuse mode
+type readerSource struct {
+	c      Codec
+	rs     Resetter
+	mode   string
+	reused io.ReadCloser
+	pool   sync.Pool
+}
+
+// newReaderSource skips the benchmark when the mode needs Reset and the codec cannot provide it
+func newReaderSource(b *testing.B, c Codec, mode string) *readerSource {
+	rs, ok := c.(Resetter)
+	if mode != reuseFresh && !ok {
+		b.Skipf("%s decoders cannot be reset", c.Name())
+	}
+	return &readerSource{c: c, rs: rs, mode: mode}
+}
+
+func (s *readerSource) get(r io.Reader) (io.ReadCloser, error) {
+	var zr io.ReadCloser
+	switch s.mode {
+	case reuseReset:
+		zr = s.reused
+	case reusePool:
+		zr, _ = s.pool.Get().(io.ReadCloser)
+	}
+	if zr == nil {
+		return s.c.NewReader(r)
+	}
+	return zr, s.rs.ResetReader(zr, r)
+}
+
+// put releases a fully read decoder; fresh decoders are closed, reusable ones are kept open
+func (s *readerSource) put(zr io.ReadCloser) {
+	switch s.mode {
+	case reuseFresh:
+		zr.Close()
+	case reuseReset:
+		s.reused = zr
+	case reusePool:
+		s.pool.Put(zr)
+	}
+}
+
+// close releases any decoders still held by the source
+func (s *readerSource) close() {
+	if s.reused != nil {
+		s.reused.Close()
+	}
+	for {
+		zr, _ := s.pool.Get().(io.ReadCloser)
+		if zr == nil {
+			return
+		}
+		zr.Close()
+	}
+}
@@ -0,0 +1,336 @@
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+// robustnessInputSize keeps corrupted-input tests fast while spanning several blocks of every format
+const robustnessInputSize = 64 << 10
+
+// robustnessTimeout bounds a single decompression of corrupted input; exceeding it is reported as a hang
+const robustnessTimeout = 10 * time.Second
+
+// APIs a codec's output can be produced and consumed with
+const (
+	apiOneShot = "one-shot"
+	apiStream  = "stream"
+)
+
+var robustnessAPIs = []string{apiOneShot, apiStream}
+
+// Kinds of damage applied to valid compressed data
+const (
+	corruptTruncated    = "truncated"
+	corruptBitFlip      = "bitflip"
+	corruptChecksum     = "checksum"
+	corruptConcatenated = "concatenated"
+)
+
+// checksumOffsets locates a byte of each codec's integrity checksum, per API; missing codecs have none
+var checksumOffsets = map[string]map[string]func(n int) int{
+	"gzip-klauspost":   {apiOneShot: gzipCRCOffset, apiStream: gzipCRCOffset},
+	"gzip-stdlib":      {apiOneShot: gzipCRCOffset, apiStream: gzipCRCOffset},
+	"zlib-klauspost":   {apiOneShot: trailerOffset, apiStream: trailerOffset},
+	"zlib-stdlib":      {apiOneShot: trailerOffset, apiStream: trailerOffset},
+	"zstd-klauspost":   {apiOneShot: trailerOffset, apiStream: trailerOffset},
+	"s2-klauspost":     {apiStream: s2ChunkCRCOffset},
+	"snappy-klauspost": {apiStream: s2ChunkCRCOffset},
+	"snappy-golang":    {apiStream: s2ChunkCRCOffset},
+}
+
+// gzipCRCOffset is the first byte of the CRC-32 in the 8-byte gzip trailer
+func gzipCRCOffset(n int) int { return n - 8 }
+
+// trailerOffset is the last byte of a trailing checksum: the zlib Adler-32 or the zstd content checksum
+func trailerOffset(n int) int { return n - 1 }
+
+// s2ChunkCRCOffset is the CRC-32C of the first data chunk, after the 10-byte stream identifier and 4-byte chunk header
+func s2ChunkCRCOffset(int) int { return 14 }
+
+// concatenationTolerated lists codec APIs whose formats stop at the end of the first stream
+var concatenationTolerated = map[string]map[string]bool{
+	"flate-klauspost": {apiOneShot: true, apiStream: true},
+	"flate-stdlib":    {apiOneShot: true, apiStream: true},
+	"zlib-klauspost":  {apiOneShot: true, apiStream: true},
+	"zlib-stdlib":     {apiOneShot: true, apiStream: true},
+}
+
+// corruptionKnownIssues records damage a decoder is known to accept silently, keyed by codec/api/kind
+var corruptionKnownIssues = map[string]string{
+	"zstd-datadog/stream/truncated": "DataDog's streaming reader returns io.EOF instead of an error when a frame is cut short, " + datadogZstdIssues,
+}
+
+// robustnessCompress produces valid output of a codec through the given API
+func robustnessCompress(c Codec, api string, data []byte, level int) ([]byte, error) {
+	if api == apiStream {
+		return compressStream(c, nil, data, level)
+	}
+	return c.Compress(nil, data, level)
+}
+
+// robustnessDecompress decompresses src through the given API
+func robustnessDecompress(c Codec, api string, src []byte) ([]byte, error) {
+	if api == apiStream {
+		return decompressStream(c, nil, src)
+	}
+	return c.Decompress(nil, src)
+}
+
+// decompressGuarded runs a decompression, failing the test on a panic or a timeout
+func decompressGuarded(tb testing.TB, c Codec, api string, src []byte) ([]byte, error) {
+	tb.Helper()
+
+	type result struct {
+		out      []byte
+		err      error
+		panicked any
+		stack    []byte
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{panicked: p, stack: debug.Stack()}
+			}
+		}()
+		out, err := robustnessDecompress(c, api, src)
+		done <- result{out: out, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.panicked != nil {
+			tb.Fatalf("%s %s decompress panicked: %v\n%s", c.Name(), api, r.panicked, r.stack)
+		}
+		return r.out, r.err
+	case <-time.After(robustnessTimeout):
+		tb.Fatalf("%s %s decompress did not return within %v", c.Name(), api, robustnessTimeout)
+		return nil, nil
+	}
+}
+
+// corruptCase is a damaged input and the kind of damage applied
+type corruptCase struct {
+	kind    string
+	name    string
+	input   []byte
+	checked bool // whether the damage must be reported as an error rather than decode to other data
+}
+
+// corruptCases derives truncated, bit-flipped, wrong-checksum and concatenated inputs from valid compressed data
+func corruptCases(c Codec, api string, compressed []byte) []corruptCase {
+	n := len(compressed)
+	checksum, checked := checksumOffsets[c.Name()][api]
+
+	var cases []corruptCase
+	for _, cut := range []int{1, n / 4, n / 2, n - 1} {
+		if cut <= 0 || cut >= n {
+			continue
+		}
+		cases = append(cases, corruptCase{
+			kind:    corruptTruncated,
+			name:    fmt.Sprintf("%s/at=%d", corruptTruncated, cut),
+			input:   compressed[:cut],
+			checked: true,
+		})
+	}
+
+	for _, off := range []int{n / 4, n / 2, 3 * n / 4} {
+		cases = append(cases, corruptCase{
+			kind:    corruptBitFlip,
+			name:    fmt.Sprintf("%s/at=%d", corruptBitFlip, off),
+			input:   flipBit(compressed, off, 0x10),
+			checked: checked,
+		})
+	}
+
+	if checked {
+		off := checksum(n)
+		cases = append(cases, corruptCase{
+			kind:    corruptChecksum,
+			name:    fmt.Sprintf("%s/at=%d", corruptChecksum, off),
+			input:   flipBit(compressed, off, 0x01),
+			checked: true,
+		})
+	}
+
+	cases = append(cases, corruptCase{
+		kind:    corruptConcatenated,
+		name:    corruptConcatenated,
+		input:   append(bytes.Clone(compressed), compressed...),
+		checked: true,
+	})
+	return cases
+}
+
+// flipBit returns a copy of b with the bits in mask inverted at offset off
+func flipBit(b []byte, off int, mask byte) []byte {
+	out := bytes.Clone(b)
+	out[off] ^= mask
+	return out
+}
+
+// TestCorruptedInput checks that damaged input is rejected rather than panicking, hanging or decoding wrongly
+func TestCorruptedInput(t *testing.T) {
+	for _, c := range Codecs() {
+		t.Run("codec="+c.Name(), func(t *testing.T) {
+			level := c.Levels()[0]
+			for _, api := range robustnessAPIs {
+				t.Run("api="+api, func(t *testing.T) {
+					for _, dataType := range benchmarkDataTypes {
+						t.Run("data="+dataType, func(t *testing.T) {
+							data := testData(t, robustnessInputSize, dataType)
+							compressed, err := robustnessCompress(c, api, data, level)
+							if err != nil {
+								t.Fatal(err)
+							}
+							for _, cc := range corruptCases(c, api, compressed) {
+								t.Run(cc.name, func(t *testing.T) {
+									checkCorrupted(t, c, api, data, cc, corruptionKnownIssues[c.Name()+"/"+api+"/"+cc.kind])
+								})
+							}
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+// checkCorrupted decompresses one damaged input, skipping the case for a known issue
+func checkCorrupted(t *testing.T, c Codec, api string, data []byte, cc corruptCase, knownIssue string) {
+	out, err := decompressGuarded(t, c, api, cc.input)
+	if err != nil {
+		return
+	}
+	fail := t.Errorf
+	if knownIssue != "" {
+		fail = func(format string, args ...any) {
+			t.Skipf("known issue: %s: "+format, append([]any{knownIssue}, args...)...)
+		}
+	}
+
+	if cc.kind == corruptConcatenated {
+		switch {
+		case bytes.Equal(out, append(bytes.Clone(data), data...)):
+		case bytes.Equal(out, data) && concatenationTolerated[c.Name()][api]:
+		default:
+			fail("%s %s decoded concatenated streams to %d bytes without error, want %d or an error",
+				c.Name(), api, len(out), 2*len(data))
+		}
+		return
+	}
+
+	if bytes.Equal(out, data) {
+		// Damage that did not change the decoded output, e.g. a flipped padding bit
+		return
+	}
+	if !cc.checked {
+		t.Logf("%s %s has no checksum and decoded damaged input to different data without error", c.Name(), api)
+		return
+	}
+	fail("%s %s decoded damaged input to %d bytes of different data without error", c.Name(), api, len(out))
+}
+
+// FuzzDecompressCorrupted flips fuzzer-chosen bits in compressed fuzzer data
+func FuzzDecompressCorrupted(f *testing.F) {
+	for _, c := range Codecs() {
+		for _, dataType := range benchmarkDataTypes {
+			f.Add(c.Name(), apiOneShot, testData(f, 4<<10, dataType), uint32(100), byte(0x10))
+			f.Add(c.Name(), apiStream, testData(f, 4<<10, dataType), uint32(100), byte(0x10))
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, name, api string, data []byte, offset uint32, mask byte) {
+		c, ok := LookupCodec(name)
+		if !ok || (api != apiOneShot && api != apiStream) || mask == 0 {
+			t.Skip()
+		}
+		compressed, err := robustnessCompress(c, api, data, c.Levels()[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(compressed) == 0 {
+			t.Skip()
+		}
+		damaged := flipBit(compressed, int(offset%uint32(len(compressed))), mask)
+
+		out, err := decompressGuarded(t, c, api, damaged)
+		if err != nil || bytes.Equal(out, data) {
+			return
+		}
+		if _, checked := checksumOffsets[name][api]; checked {
+			t.Errorf("%s %s decoded damaged input to different data without error", name, api)
+		}
+	})
+}
+
+// FuzzDecompressArbitrary feeds arbitrary bytes to every decompressor
+func FuzzDecompressArbitrary(f *testing.F) {
+	for _, c := range Codecs() {
+		for _, api := range robustnessAPIs {
+			for _, dataType := range benchmarkDataTypes {
+				compressed, err := robustnessCompress(c, api, testData(f, 4<<10, dataType), c.Levels()[0])
+				if err != nil {
+					f.Fatal(err)
+				}
+				f.Add(c.Name(), api, compressed)
+			}
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, name, api string, input []byte) {
+		c, ok := LookupCodec(name)
+		if !ok || (api != apiOneShot && api != apiStream) {
+			t.Skip()
+		}
+		decompressGuarded(t, c, api, input)
+	})
+}
+
+// BenchmarkCorruptedInputRejection measures how quickly each decompressor rejects damaged input
+func BenchmarkCorruptedInputRejection(b *testing.B) {
+	data := testData(b, SmallSize, TextData)
+
+	for _, c := range Codecs() {
+		b.Run("codec="+c.Name(), func(b *testing.B) {
+			compressed, err := compressStream(c, nil, data, c.Levels()[0])
+			if err != nil {
+				b.Fatal(err)
+			}
+			n := len(compressed)
+
+			damaged := map[string][]byte{
+				corruptTruncated: compressed[:n/2],
+				corruptBitFlip:   flipBit(compressed, n/2, 0x10),
+			}
+			if checksum, ok := checksumOffsets[c.Name()][apiStream]; ok {
+				damaged[corruptChecksum] = flipBit(compressed, checksum(n), 0x01)
+			}
+
+			for _, kind := range []string{corruptTruncated, corruptBitFlip, corruptChecksum} {
+				b.Run("corruption="+kind, func(b *testing.B) {
+					input, ok := damaged[kind]
+					if !ok {
+						b.Skipf("%s streams carry no checksum", c.Name())
+					}
+					if _, err := decompressStream(c, nil, input); err == nil {
+						b.Skipf("%s does not detect %s input", c.Name(), kind)
+					}
+
+					b.ResetTimer()
+					b.SetBytes(int64(len(input)))
+					for i := 0; i < b.N; i++ {
+						if _, err := decompressStream(c, nil, input); err == nil {
+							b.Fatal("Damaged input decompressed without error")
+						}
+					}
+				})
+			}
+		})
+	}
+}
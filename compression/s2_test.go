@@ -0,0 +1,136 @@
+package compression
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/klauspost/compress/s2"
+)
+
+// s2Levels select the S2 encoder mode; S2 has no numeric levels of its own
+var s2Levels = []int{1, 2, 3}
+
+// s2Modes names the encoder mode behind each entry in s2Levels
+var s2Modes = map[int]string{
+	1: "default",
+	2: "better",
+	3: "best",
+}
+
+func init() {
+	RegisterCodec(s2Codec{})
+	RegisterCodec(s2Codec{snappy: true})
+}
+
+// s2Codec adapts github.com/klauspost/compress/s2, or its Snappy-compatible mode, to the Codec interface
+type s2Codec struct {
+	snappy bool
+}
+
+func (c s2Codec) Name() string {
+	if c.snappy {
+		return "snappy-klauspost"
+	}
+	return "s2-klauspost"
+}
+
+func (s2Codec) Levels() []int { return s2Levels }
+
+// DescribeLevel reports which S2 encoder mode a level runs as
+func (s2Codec) DescribeLevel(level int) string {
+	if mode, ok := s2Modes[level]; ok {
+		return mode
+	}
+	return fmt.Sprintf("unsupported level %d", level)
+}
+
+func (c s2Codec) Compress(dst, src []byte, level int) ([]byte, error) {
+	encode, err := c.blockEncoder(level)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode into the spare capacity of dst so the block is appended without a copy
+	n := s2.MaxEncodedLen(len(src))
+	if n < 0 {
+		return nil, s2.ErrTooLarge
+	}
+	dst = slices.Grow(dst, n)
+	block := encode(dst[len(dst):len(dst)+n], src)
+	return dst[:len(dst)+len(block)], nil
+}
+
+func (s2Codec) Decompress(dst, src []byte) ([]byte, error) {
+	n, err := s2.DecodedLen(src)
+	if err != nil {
+		return nil, err
+	}
+	dst = slices.Grow(dst, n)
+	block, err := s2.Decode(dst[len(dst):len(dst)+n], src)
+	if err != nil {
+		return nil, err
+	}
+	return dst[:len(dst)+len(block)], nil
+}
+
+func (c s2Codec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	var opts []s2.WriterOption
+	switch level {
+	case 1:
+	case 2:
+		opts = append(opts, s2.WriterBetterCompression())
+	case 3:
+		opts = append(opts, s2.WriterBestCompression())
+	default:
+		return nil, fmt.Errorf("%s: unsupported level %d (want one of %v)", c.Name(), level, s2Levels)
+	}
+	if c.snappy {
+		opts = append(opts, s2.WriterSnappyCompat())
+	}
+	return s2.NewWriter(w, opts...), nil
+}
+
+func (s2Codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	// The S2 stream decoder also reads the Snappy framing format
+	return s2Reader{s2.NewReader(r)}, nil
+}
+
+func (s2Codec) ResetWriter(zw io.WriteCloser, w io.Writer) error {
+	return resetWriter(zw, w)
+}
+
+func (s2Codec) ResetReader(zr io.ReadCloser, r io.Reader) error {
+	return resetReader(zr, r)
+}
+
+// blockEncoder returns the one-shot block encoder for a level
+func (c s2Codec) blockEncoder(level int) (func(dst, src []byte) []byte, error) {
+	switch {
+	case level == 1 && c.snappy:
+		return s2.EncodeSnappy, nil
+	case level == 2 && c.snappy:
+		return s2.EncodeSnappyBetter, nil
+	case level == 3 && c.snappy:
+		return s2.EncodeSnappyBest, nil
+	case level == 1:
+		return s2.Encode, nil
+	case level == 2:
+		return s2.EncodeBetter, nil
+	case level == 3:
+		return s2.EncodeBest, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported level %d (want one of %v)", c.Name(), level, s2Levels)
+}
+
+// s2Reader adds the Close and error-returning Reset methods the driver expects to s2.Reader
+type s2Reader struct {
+	*s2.Reader
+}
+
+func (s2Reader) Close() error { return nil }
+
+func (r s2Reader) Reset(src io.Reader) error {
+	r.Reader.Reset(src)
+	return nil
+}
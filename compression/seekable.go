@@ -0,0 +1,191 @@
+package compression
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Constants of the zstd seekable format (contrib/seekable_format in the zstd repository)
+const (
+	seekTableMagic      = 0x184D2A5E // skippable frame magic number used for the seek table
+	seekableMagic       = 0x8F92EAB1 // last four bytes of a seekable stream
+	seekTableFooterSize = 9          // number of frames, descriptor, seekable magic
+	seekTableEntrySize  = 8          // compressed and decompressed size; checksums are not written
+	skippableHeaderSize = 8          // skippable magic and frame size
+)
+
+// SeekableWriter compresses input into independent zstd frames followed by a seek table
+type SeekableWriter struct {
+	w         io.Writer
+	enc       *zstd.Encoder
+	frameSize int
+	buf       []byte
+	frame     []byte
+	entries   []seekEntry
+	err       error
+}
+
+// seekEntry locates one frame
+type seekEntry struct {
+	compressed, decompressed uint32
+}
+
+// NewSeekableWriter returns a writer that compresses each frame of frameSize bytes with enc
+func NewSeekableWriter(w io.Writer, enc *zstd.Encoder, frameSize int) (*SeekableWriter, error) {
+	if frameSize <= 0 || int64(frameSize) > 1<<32-1 {
+		return nil, fmt.Errorf("compression: invalid seekable frame size %d", frameSize)
+	}
+	return &SeekableWriter{w: w, enc: enc, frameSize: frameSize, buf: make([]byte, 0, frameSize)}, nil
+}
+
+func (s *SeekableWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 && s.err == nil {
+		n := min(s.frameSize-len(s.buf), len(p))
+		s.buf = append(s.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(s.buf) == s.frameSize {
+			s.flushFrame()
+		}
+	}
+	return written, s.err
+}
+
+// Close writes the final frame and the seek table, without closing the underlying writer
+func (s *SeekableWriter) Close() error {
+	if len(s.buf) > 0 {
+		s.flushFrame()
+	}
+	if s.err != nil {
+		return s.err
+	}
+
+	tableSize := len(s.entries)*seekTableEntrySize + seekTableFooterSize
+	table := make([]byte, 0, skippableHeaderSize+tableSize)
+	table = binary.LittleEndian.AppendUint32(table, seekTableMagic)
+	table = binary.LittleEndian.AppendUint32(table, uint32(tableSize))
+	for _, e := range s.entries {
+		table = binary.LittleEndian.AppendUint32(table, e.compressed)
+		table = binary.LittleEndian.AppendUint32(table, e.decompressed)
+	}
+	table = binary.LittleEndian.AppendUint32(table, uint32(len(s.entries)))
+	table = append(table, 0) // descriptor: no checksums
+	table = binary.LittleEndian.AppendUint32(table, seekableMagic)
+	_, s.err = s.w.Write(table)
+	return s.err
+}
+
+// flushFrame compresses the buffered input as one frame
+func (s *SeekableWriter) flushFrame() {
+	s.frame = s.enc.EncodeAll(s.buf, s.frame[:0])
+	if _, err := s.w.Write(s.frame); err != nil {
+		s.err = err
+		return
+	}
+	s.entries = append(s.entries, seekEntry{uint32(len(s.frame)), uint32(len(s.buf))})
+	s.buf = s.buf[:0]
+}
+
+// errNotSeekable is returned for streams that do not end with a valid seek table
+var errNotSeekable = errors.New("compression: not a seekable zstd stream")
+
+// SeekableReader decodes arbitrary ranges of a seekable zstd stream; it is not safe for concurrent use
+type SeekableReader struct {
+	r     io.ReaderAt
+	dec   *zstd.Decoder
+	size  int64   // total decompressed size
+	cOffs []int64 // compressed offset of each frame, plus the end of the last frame
+	dOffs []int64 // decompressed offset of each frame, plus size
+	src   []byte
+	frame []byte
+}
+
+// NewSeekableReader reads the seek table of a seekable stream of size compressed bytes
+func NewSeekableReader(r io.ReaderAt, size int64, dec *zstd.Decoder) (*SeekableReader, error) {
+	if size < skippableHeaderSize+seekTableFooterSize {
+		return nil, errNotSeekable
+	}
+	footer := make([]byte, seekTableFooterSize)
+	if _, err := r.ReadAt(footer, size-seekTableFooterSize); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableMagic {
+		return nil, errNotSeekable
+	}
+	frames := int64(binary.LittleEndian.Uint32(footer))
+	entrySize := int64(seekTableEntrySize)
+	if footer[4]&0x80 != 0 {
+		entrySize += 4 // per-frame checksums, written by other implementations
+	}
+	tableSize := frames*entrySize + seekTableFooterSize
+	if skippableHeaderSize+tableSize > size {
+		return nil, errNotSeekable
+	}
+
+	table := make([]byte, skippableHeaderSize+tableSize)
+	if _, err := r.ReadAt(table, size-int64(len(table))); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(table) != seekTableMagic || int64(binary.LittleEndian.Uint32(table[4:])) != tableSize {
+		return nil, errNotSeekable
+	}
+
+	s := &SeekableReader{r: r, dec: dec, cOffs: make([]int64, 1, frames+1), dOffs: make([]int64, 1, frames+1)}
+	for i := range frames {
+		entry := table[skippableHeaderSize+i*entrySize:]
+		s.cOffs = append(s.cOffs, s.cOffs[i]+int64(binary.LittleEndian.Uint32(entry)))
+		s.dOffs = append(s.dOffs, s.dOffs[i]+int64(binary.LittleEndian.Uint32(entry[4:])))
+	}
+	if s.cOffs[frames] != size-int64(len(table)) {
+		return nil, errNotSeekable
+	}
+	s.size = s.dOffs[frames]
+	return s, nil
+}
+
+// Size returns the decompressed size of the stream
+func (s *SeekableReader) Size() int64 { return s.size }
+
+// Frames returns the number of compressed frames
+func (s *SeekableReader) Frames() int { return len(s.cOffs) - 1 }
+
+// ReadAt decodes len(p) bytes starting at decompressed offset off
+func (s *SeekableReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("compression: negative offset %d", off)
+	}
+	if off >= s.size {
+		return 0, io.EOF
+	}
+
+	// The frame holding off is the last one starting at or before it
+	i := sort.Search(len(s.dOffs), func(i int) bool { return s.dOffs[i] > off }) - 1
+	n := 0
+	for n < len(p) && i < s.Frames() {
+		s.src = slices.Grow(s.src[:0], int(s.cOffs[i+1]-s.cOffs[i]))[:s.cOffs[i+1]-s.cOffs[i]]
+		if _, err := s.r.ReadAt(s.src, s.cOffs[i]); err != nil {
+			return n, err
+		}
+		var err error
+		s.frame, err = s.dec.DecodeAll(s.src, s.frame[:0])
+		if err != nil {
+			return n, err
+		}
+		if int64(len(s.frame)) != s.dOffs[i+1]-s.dOffs[i] {
+			return n, fmt.Errorf("compression: frame %d decoded to %d bytes, seek table says %d", i, len(s.frame), s.dOffs[i+1]-s.dOffs[i])
+		}
+		n += copy(p[n:], s.frame[off+int64(n)-s.dOffs[i]:])
+		i++
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,284 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"testing"
+
+	klauspost "github.com/klauspost/compress/zstd"
+)
+
+// Seekable zstd frame and read sizes
+var (
+	seekableFrameSizes = []int{64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+	seekableReadSizes  = []int{4 << 10, 64 << 10, 1 << 20}
+)
+
+// seekableLevel is the zstd level every frame is compressed at
+const seekableLevel = 3
+
+// seekableReads is the number of precomputed random offsets a read benchmark cycles through
+const seekableReads = 1024
+
+// compressSeekable compresses data into frames of frameSize with the shared klauspost encoder
+func compressSeekable(tb testing.TB, data []byte, frameSize int) []byte {
+	tb.Helper()
+	enc, err := klauspostZstd.encoder(seekableLevel)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeSeekable(&buf, enc, data, frameSize); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeSeekable compresses data into frames of frameSize and appends the stream to buf
+func writeSeekable(buf *bytes.Buffer, enc *klauspost.Encoder, data []byte, frameSize int) error {
+	sw, err := NewSeekableWriter(buf, enc, frameSize)
+	if err != nil {
+		return err
+	}
+	if _, err := sw.Write(data); err != nil {
+		return err
+	}
+	return sw.Close()
+}
+
+// newSeekableReader opens a seekable stream with a single-threaded decoder, as random reads decode one frame at a time
+func newSeekableReader(tb testing.TB, compressed []byte) *SeekableReader {
+	tb.Helper()
+	dec, err := klauspost.NewReader(nil, klauspost.WithDecoderConcurrency(1))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(dec.Close)
+	sr, err := NewSeekableReader(bytes.NewReader(compressed), int64(len(compressed)), dec)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return sr
+}
+
+// seekableOffsets returns reproducible random offsets at which a read of readSize fits in size bytes
+func seekableOffsets(size, readSize int) []int64 {
+	rng := rand.New(rand.NewPCG(DefaultSeed, uint64(readSize)))
+	offsets := make([]int64, seekableReads)
+	for i := range offsets {
+		offsets[i] = rng.Int64N(int64(size - readSize + 1))
+	}
+	return offsets
+}
+
+// frameLabel names a frame size, with frame=none for a single frame
+func frameLabel(frameSize int) string {
+	if frameSize == 0 {
+		return "frame=none"
+	}
+	return "frame=" + sizeLabel(frameSize)
+}
+
+// BenchmarkSeekableZstdCompression measures compressing into seekable frames and the ratio cost over a single frame
+func BenchmarkSeekableZstdCompression(b *testing.B) {
+	runSeekableMatrix(b, func(b *testing.B, data []byte) {
+		single, err := klauspostZstd.Compress(nil, data, seekableLevel)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(frameLabel(0), func(b *testing.B) {
+			benchmarkCompressData(b, klauspostZstd, data, seekableLevel)
+		})
+		for _, frameSize := range seekableFrameSizes {
+			b.Run(frameLabel(frameSize), func(b *testing.B) {
+				enc, err := klauspostZstd.encoder(seekableLevel)
+				if err != nil {
+					b.Fatal(err)
+				}
+				// The output buffer is sized once and reused, so only compression is timed
+				var buf bytes.Buffer
+				buf.Grow(len(compressSeekable(b, data, frameSize)))
+
+				b.ResetTimer()
+				b.SetBytes(int64(len(data)))
+				for i := 0; i < b.N; i++ {
+					buf.Reset()
+					if err := writeSeekable(&buf, enc, data, frameSize); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StopTimer()
+
+				compressed := buf.Bytes()
+				b.ReportMetric(float64(len(data))/float64(len(compressed)), "ratio")
+				b.ReportMetric((float64(len(compressed))/float64(len(single))-1)*100, "ratio-cost-%")
+			})
+		}
+	})
+}
+
+// BenchmarkSeekableZstdRead measures reads at random offsets against decoding a single frame
+func BenchmarkSeekableZstdRead(b *testing.B) {
+	runSeekableMatrix(b, func(b *testing.B, data []byte) {
+		single, err := klauspostZstd.Compress(nil, data, seekableLevel)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(frameLabel(0), func(b *testing.B) {
+			for _, readSize := range seekableReadSizes {
+				b.Run("read="+sizeLabel(readSize), func(b *testing.B) {
+					benchmarkStreamPrefixRead(b, single, len(data), readSize)
+				})
+			}
+			b.Run("read=full", func(b *testing.B) {
+				benchmarkFullDecode(b, single, len(data))
+			})
+		})
+		for _, frameSize := range seekableFrameSizes {
+			b.Run(frameLabel(frameSize), func(b *testing.B) {
+				compressed := compressSeekable(b, data, frameSize)
+				for _, readSize := range seekableReadSizes {
+					b.Run("read="+sizeLabel(readSize), func(b *testing.B) {
+						benchmarkSeekableRead(b, compressed, data, readSize)
+					})
+				}
+				b.Run("read=full", func(b *testing.B) {
+					benchmarkFullDecode(b, compressed, len(data))
+				})
+			})
+		}
+	})
+}
+
+// runSeekableMatrix runs fn on LargeSize data of every type
+func runSeekableMatrix(b *testing.B, fn func(b *testing.B, data []byte)) {
+	b.Run("size="+sizeLabel(LargeSize), func(b *testing.B) {
+		for _, dataType := range benchmarkDataTypes {
+			b.Run("data="+dataType, func(b *testing.B) {
+				fn(b, testData(b, LargeSize, dataType))
+			})
+		}
+	})
+}
+
+func benchmarkSeekableRead(b *testing.B, compressed, data []byte, readSize int) {
+	sr := newSeekableReader(b, compressed)
+	offsets := seekableOffsets(len(data), readSize)
+	p := make([]byte, readSize)
+
+	// Verify one read
+	if _, err := sr.ReadAt(p, offsets[0]); err != nil {
+		b.Fatal(err)
+	}
+	if !bytes.Equal(p, data[offsets[0]:offsets[0]+int64(readSize)]) {
+		b.Fatal("Read data does not match original")
+	}
+
+	b.ResetTimer()
+	b.SetBytes(int64(readSize))
+	for i := 0; i < b.N; i++ {
+		if _, err := sr.ReadAt(p, offsets[i%len(offsets)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkStreamPrefixRead reads at random offsets from a single-frame stream, decoding from the start
+func benchmarkStreamPrefixRead(b *testing.B, compressed []byte, size, readSize int) {
+	offsets := seekableOffsets(size, readSize)
+	r := bytes.NewReader(compressed)
+	dec, err := klauspost.NewReader(r)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer dec.Close()
+	p := make([]byte, readSize)
+
+	b.ResetTimer()
+	b.SetBytes(int64(readSize))
+	for i := 0; i < b.N; i++ {
+		r.Reset(compressed)
+		if err := dec.Reset(r); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.CopyN(io.Discard, dec, offsets[i%len(offsets)]); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.ReadFull(dec, p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkFullDecode decodes a whole stream with a standard streaming decoder, which skips the seek table
+func benchmarkFullDecode(b *testing.B, compressed []byte, size int) {
+	r := bytes.NewReader(compressed)
+	dec, err := klauspost.NewReader(r)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer dec.Close()
+
+	b.ResetTimer()
+	b.SetBytes(int64(size))
+	for i := 0; i < b.N; i++ {
+		r.Reset(compressed)
+		if err := dec.Reset(r); err != nil {
+			b.Fatal(err)
+		}
+		n, err := io.Copy(io.Discard, dec)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if n != int64(size) {
+			b.Fatalf("Decoded %d bytes, want %d", n, size)
+		}
+	}
+}
+
+// TestSeekableZstd checks random-access reads and that an ordinary decoder reads a seekable stream
+func TestSeekableZstd(t *testing.T) {
+	const frameSize = 4 << 10
+	data := testData(t, SmallSize+123, TextData)
+	compressed := compressSeekable(t, data, frameSize)
+	sr := newSeekableReader(t, compressed)
+
+	if sr.Size() != int64(len(data)) {
+		t.Fatalf("seek table covers %d bytes, want %d", sr.Size(), len(data))
+	}
+	if want := (len(data) + frameSize - 1) / frameSize; sr.Frames() != want {
+		t.Fatalf("got %d frames, want %d", sr.Frames(), want)
+	}
+
+	for _, readSize := range []int{1, 100, frameSize, 3*frameSize + 7} {
+		for _, off := range seekableOffsets(len(data), readSize)[:32] {
+			p := make([]byte, readSize)
+			if _, err := sr.ReadAt(p, off); err != nil {
+				t.Fatalf("ReadAt(%d bytes, %d): %v", readSize, off, err)
+			}
+			if !bytes.Equal(p, data[off:off+int64(readSize)]) {
+				t.Fatalf("ReadAt(%d bytes, %d) returned the wrong data", readSize, off)
+			}
+		}
+	}
+
+	p := make([]byte, 200)
+	if n, err := sr.ReadAt(p, int64(len(data)-100)); n != 100 || err != io.EOF {
+		t.Errorf("read past the end returned %d, %v; want 100, io.EOF", n, err)
+	}
+
+	// github.com/DataDog/zstd fails on multi-frame streams (see TestDatadogZstdMultiFrame), so only klauspost
+	// reads the whole stream
+	got, err := klauspostZstd.Decompress(nil, compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, got) {
+		t.Fatal("decompressed data does not match original")
+	}
+
+	if _, err := NewSeekableReader(bytes.NewReader(data), int64(len(data)), nil); err == nil {
+		t.Error("opened a stream without a seek table")
+	}
+}
@@ -0,0 +1,29 @@
+package compression
+
+import "testing"
+
+// BenchmarkSmallPayloadCompression measures one-shot compression of RPC-sized messages
+func BenchmarkSmallPayloadCompression(b *testing.B) {
+	runCodecMatrix(b, SmallPayloadSizes, func(b *testing.B, c Codec, size int, dataType string, level int) {
+		b.ReportAllocs()
+		benchmarkCompress(b, c, size, dataType, level)
+		reportSavedBytes(b, c, size, dataType, level)
+	})
+}
+
+// BenchmarkSmallPayloadDecompression measures one-shot decompression of RPC-sized messages for every codec
+func BenchmarkSmallPayloadDecompression(b *testing.B) {
+	runCodecMatrix(b, SmallPayloadSizes, func(b *testing.B, c Codec, size int, dataType string, level int) {
+		b.ReportAllocs()
+		benchmarkDecompress(b, c, size, dataType, level)
+	})
+}
+
+// reportSavedBytes reports how many bytes compression saves per message, framing included
+func reportSavedBytes(b *testing.B, c Codec, size int, dataType string, level int) {
+	compressed, err := c.Compress(nil, testData(b, size, dataType), level)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(size-len(compressed)), "saved-B")
+}
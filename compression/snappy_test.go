@@ -0,0 +1,86 @@
+package compression
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/golang/snappy"
+)
+
+// snappyLevels holds the single level of github.com/golang/snappy, which has one encoder mode
+var snappyLevels = []int{1}
+
+func init() {
+	RegisterCodec(snappyCodec{})
+}
+
+// snappyCodec adapts github.com/golang/snappy to the Codec interface
+type snappyCodec struct{}
+
+func (snappyCodec) Name() string  { return "snappy-golang" }
+func (snappyCodec) Levels() []int { return snappyLevels }
+
+func (snappyCodec) Compress(dst, src []byte, level int) ([]byte, error) {
+	if err := checkSnappyLevel(level); err != nil {
+		return nil, err
+	}
+	n := snappy.MaxEncodedLen(len(src))
+	if n < 0 {
+		return nil, snappy.ErrTooLarge
+	}
+	dst = slices.Grow(dst, n)
+	block := snappy.Encode(dst[len(dst):len(dst)+n], src)
+	return dst[:len(dst)+len(block)], nil
+}
+
+func (snappyCodec) Decompress(dst, src []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(src)
+	if err != nil {
+		return nil, err
+	}
+	dst = slices.Grow(dst, n)
+	block, err := snappy.Decode(dst[len(dst):len(dst)+n], src)
+	if err != nil {
+		return nil, err
+	}
+	return dst[:len(dst)+len(block)], nil
+}
+
+func (snappyCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if err := checkSnappyLevel(level); err != nil {
+		return nil, err
+	}
+	return snappy.NewBufferedWriter(w), nil
+}
+
+func (snappyCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return snappyReader{snappy.NewReader(r)}, nil
+}
+
+func (snappyCodec) ResetWriter(zw io.WriteCloser, w io.Writer) error {
+	return resetWriter(zw, w)
+}
+
+func (snappyCodec) ResetReader(zr io.ReadCloser, r io.Reader) error {
+	return resetReader(zr, r)
+}
+
+func checkSnappyLevel(level int) error {
+	if !slices.Contains(snappyLevels, level) {
+		return fmt.Errorf("snappy-golang: unsupported level %d (want one of %v)", level, snappyLevels)
+	}
+	return nil
+}
+
+// snappyReader adds the Close and error-returning Reset methods the driver expects to snappy.Reader
+type snappyReader struct {
+	*snappy.Reader
+}
+
+func (snappyReader) Close() error { return nil }
+
+func (r snappyReader) Reset(src io.Reader) error {
+	r.Reader.Reset(src)
+	return nil
+}
@@ -0,0 +1,140 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// streamSizes are the payload sizes pushed through the streaming APIs
+var streamSizes = []int{MediumSize}
+
+// streamChunkSizes are the Write and Read sizes used by the streaming benchmarks
+var streamChunkSizes = []int{512, 4 << 10, 64 << 10, 1 << 20}
+
+// streamFlushInterval is how much data is written between Flush calls, mimicking a log shipper's flush cadence
+const streamFlushInterval = 256 << 10
+
+// flusher is implemented by streaming writers that can emit buffered data mid-stream
+type flusher interface {
+	Flush() error
+}
+
+// BenchmarkStreamingCompression pushes data through each codec's io.Writer in fixed-size chunks with periodic Flush
+func BenchmarkStreamingCompression(b *testing.B) {
+	runCodecMatrix(b, streamSizes, func(b *testing.B, c Codec, size int, dataType string, level int) {
+		for _, chunk := range streamChunkSizes {
+			b.Run("chunk="+sizeLabel(chunk), func(b *testing.B) {
+				benchmarkStreamCompress(b, c, size, dataType, level, chunk)
+			})
+		}
+	})
+}
+
+// BenchmarkStreamingDecompression reads each codec's stream back through its io.Reader in fixed-size reads
+func BenchmarkStreamingDecompression(b *testing.B) {
+	runCodecMatrix(b, streamSizes, func(b *testing.B, c Codec, size int, dataType string, level int) {
+		for _, chunk := range streamChunkSizes {
+			b.Run("chunk="+sizeLabel(chunk), func(b *testing.B) {
+				benchmarkStreamDecompress(b, c, size, dataType, level, chunk)
+			})
+		}
+	})
+}
+
+// benchmarkStreamCompress measures streaming compression with chunk-sized writes
+func benchmarkStreamCompress(b *testing.B, c Codec, size int, dataType string, level, chunk int) {
+	data := testData(b, size, dataType)
+
+	var buf bytes.Buffer
+	b.ResetTimer()
+	b.SetBytes(int64(size))
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := writeChunked(c, &buf, data, level, chunk); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(len(data))/float64(buf.Len()), "ratio")
+}
+
+// benchmarkStreamDecompress measures streaming decompression with chunk-sized reads
+func benchmarkStreamDecompress(b *testing.B, c Codec, size int, dataType string, level, chunk int) {
+	data := testData(b, size, dataType)
+
+	var buf bytes.Buffer
+	if err := writeChunked(c, &buf, data, level, chunk); err != nil {
+		b.Fatal(err)
+	}
+	compressed := buf.Bytes()
+
+	// Verify decompression
+	decompressed, err := decompressStream(c, nil, compressed)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if !bytes.Equal(data, decompressed) {
+		b.Fatal("Decompressed data does not match original")
+	}
+
+	p := make([]byte, chunk)
+	b.ResetTimer()
+	b.SetBytes(int64(size))
+	for i := 0; i < b.N; i++ {
+		n, err := readChunked(c, bytes.NewReader(compressed), p)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if n != int64(len(data)) {
+			b.Fatalf("Decompressed %d bytes, want %d", n, len(data))
+		}
+	}
+}
+
+// writeChunked compresses data chunk bytes at a time, flushing every streamFlushInterval bytes
+func writeChunked(c Codec, w io.Writer, data []byte, level, chunk int) error {
+	zw, err := c.NewWriter(w, level)
+	if err != nil {
+		return err
+	}
+	f, canFlush := zw.(flusher)
+
+	sinceFlush := 0
+	for off := 0; off < len(data); off += chunk {
+		n, err := zw.Write(data[off:min(off+chunk, len(data))])
+		if err != nil {
+			return err
+		}
+		sinceFlush += n
+		if canFlush && sinceFlush >= streamFlushInterval {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+			sinceFlush = 0
+		}
+	}
+	return zw.Close()
+}
+
+// readChunked decompresses r using p as the read buffer
+func readChunked(c Codec, r io.Reader, p []byte) (int64, error) {
+	zr, err := c.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for {
+		n, err := zr.Read(p)
+		total += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, zr.Close()
+}
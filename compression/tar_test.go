@@ -0,0 +1,451 @@
+package compression
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// tarTreeSize is the total file content of the synthetic tree, about the size of a module or build cache
+const tarTreeSize = MediumSize
+
+// File size distribution of the tree, varied by up to ±50%
+var (
+	tarFileSizes   = []int{256, 2 << 10, 16 << 10, 128 << 10, 1 << 20}
+	tarFileWeights = []int{40, 30, 18, 9, 3}
+)
+
+// tarBinaryPercent is the share of files holding BinaryData rather than TextData
+const tarBinaryPercent = 30
+
+// tarChunkSize is the amount of the tar stream compressed into each independent chunk by the parallel variant
+const tarChunkSize = 1 << 20
+
+// treeFile is an entry of the synthetic tree; names ending in / are directories
+type treeFile struct {
+	name string
+	data []byte
+}
+
+// tarTree is the benchmark tree, generated once per process
+var tarTree = sync.OnceValue(func() []treeFile {
+	return generateTree(tarTreeSize, DefaultSeed)
+})
+
+// generateTree returns directories of text and binary files with at least size bytes of content
+func generateTree(size int, seed uint64) []treeFile {
+	rng := rand.New(rand.NewPCG(seed, 0))
+	var tree []treeFile
+	total := 0
+	for dir := 0; total < size; dir++ {
+		dirName := fmt.Sprintf("cache/pkg%03d/", dir)
+		tree = append(tree, treeFile{name: dirName})
+		for files := rng.IntN(16) + 1; files > 0 && total < size; files-- {
+			fileSize := weightedSize(rng) * (50 + rng.IntN(101)) / 100
+			dataType, ext := TextData, ".txt"
+			if rng.IntN(100) < tarBinaryPercent {
+				dataType, ext = BinaryData, ".bin"
+			}
+			name := fmt.Sprintf("%sfile%03d%s", dirName, len(tree), ext)
+			tree = append(tree, treeFile{name: name, data: GenerateTestDataSeed(fileSize, dataType, seed+uint64(len(tree)))})
+			total += fileSize
+		}
+	}
+	return tree
+}
+
+// weightedSize draws one of tarFileSizes according to tarFileWeights
+func weightedSize(rng *rand.Rand) int {
+	sum := 0
+	for _, w := range tarFileWeights {
+		sum += w
+	}
+	n := rng.IntN(sum)
+	for i, w := range tarFileWeights {
+		if n < w {
+			return tarFileSizes[i]
+		}
+		n -= w
+	}
+	return tarFileSizes[len(tarFileSizes)-1]
+}
+
+// writeTar streams the tree through archive/tar into w
+func writeTar(w io.Writer, tree []treeFile) error {
+	tw := tar.NewWriter(w)
+	for _, f := range tree {
+		hdr := &tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(f.data)), ModTime: generatorEpoch}
+		if strings.HasSuffix(f.name, "/") {
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// readTar extracts a tar stream, calling fn for every entry. data is only valid during the call.
+func readTar(r io.Reader, fn func(name string, data []byte) error) error {
+	tr := tar.NewReader(r)
+	var buf bytes.Buffer
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		buf.Reset()
+		if _, err := buf.ReadFrom(tr); err != nil {
+			return err
+		}
+		if err := fn(hdr.Name, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+}
+
+// discardEntry is a readTar callback for benchmarks, which only need the archive extracted
+func discardEntry(string, []byte) error { return nil }
+
+// compressTar streams the tree through tar and a codec's streaming writer into w
+func compressTar(c Codec, w io.Writer, tree []treeFile, level int) error {
+	zw, err := c.NewWriter(w, level)
+	if err != nil {
+		return err
+	}
+	if err := writeTar(zw, tree); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// decompressTar extracts a compressed archive through a codec's streaming reader
+func decompressTar(c Codec, compressed []byte, fn func(name string, data []byte) error) error {
+	zr, err := c.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	return readTar(zr, fn)
+}
+
+// compressChunks compresses archive in independent tarChunkSize chunks on up to threads goroutines
+func compressChunks(c Codec, archive []byte, level, threads int) ([][]byte, error) {
+	chunks := make([][]byte, (len(archive)+tarChunkSize-1)/tarChunkSize)
+	return chunks, runChunks(len(chunks), threads, func() (func(i int) error, func()) {
+		var zw io.WriteCloser
+		return func(i int) error {
+			var buf bytes.Buffer
+			var err error
+			if r, ok := c.(Resetter); ok && zw != nil {
+				err = r.ResetWriter(zw, &buf)
+			} else {
+				zw, err = c.NewWriter(&buf, level)
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := zw.Write(archive[i*tarChunkSize : min((i+1)*tarChunkSize, len(archive))]); err != nil {
+				return err
+			}
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			chunks[i] = buf.Bytes()
+			return nil
+		}, func() {}
+	})
+}
+
+// decompressChunks decodes chunks on up to threads goroutines
+func decompressChunks(c Codec, chunks [][]byte, threads int) ([][]byte, error) {
+	out := make([][]byte, len(chunks))
+	r, reset := c.(Resetter)
+	return out, runChunks(len(chunks), threads, func() (func(i int) error, func()) {
+		var zr io.ReadCloser
+		work := func(i int) error {
+			var err error
+			if reset && zr != nil {
+				err = r.ResetReader(zr, bytes.NewReader(chunks[i]))
+			} else {
+				zr, err = c.NewReader(bytes.NewReader(chunks[i]))
+			}
+			if err != nil {
+				return err
+			}
+			out[i], err = io.ReadAll(zr)
+			if !reset {
+				if closeErr := zr.Close(); err == nil {
+					err = closeErr
+				}
+			}
+			return err
+		}
+		done := func() {
+			if reset && zr != nil {
+				zr.Close()
+			}
+		}
+		return work, done
+	})
+}
+
+// runChunks runs chunks 0..n-1 through per-goroutine workers and returns the first error
+func runChunks(n, threads int, newWorker func() (work func(i int) error, done func())) error {
+	next := make(chan int)
+	errs := make(chan error, threads)
+	var wg sync.WaitGroup
+	for range min(threads, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work, done := newWorker()
+			defer done()
+			for i := range next {
+				if err := work(i); err != nil {
+					errs <- err
+					for range next {
+					}
+					return
+				}
+			}
+		}()
+	}
+	for i := range n {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// joinedReader reads chunks in order as one stream
+func joinedReader(chunks [][]byte) io.Reader {
+	readers := make([]io.Reader, len(chunks))
+	for i, chunk := range chunks {
+		readers[i] = bytes.NewReader(chunk)
+	}
+	return io.MultiReader(readers...)
+}
+
+// tarArchive returns the uncompressed tar stream of a tree
+func tarArchive(tb testing.TB, tree []treeFile) []byte {
+	tb.Helper()
+	var buf bytes.Buffer
+	if err := writeTar(&buf, tree); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// runTarMatrix runs fn as a codec=<name>/size=10MB/data=tree/level=<level> sub-benchmark
+func runTarMatrix(b *testing.B, fn func(b *testing.B, c Codec, tree []treeFile, level int)) {
+	tree := tarTree()
+	for _, c := range Codecs() {
+		b.Run("codec="+c.Name(), func(b *testing.B) {
+			b.Run("size="+sizeLabel(tarTreeSize), func(b *testing.B) {
+				runLevels(b, c, tarTreeSize, "tree", func(b *testing.B, c Codec, _ int, _ string, level int) {
+					fn(b, c, tree, level)
+				})
+			})
+		})
+	}
+}
+
+// BenchmarkTarCompression measures archiving the tree with archive/tar streamed into each codec
+func BenchmarkTarCompression(b *testing.B) {
+	runTarMatrix(b, func(b *testing.B, c Codec, tree []treeFile, level int) {
+		archiveSize := len(tarArchive(b, tree))
+		var buf bytes.Buffer
+
+		b.ResetTimer()
+		b.SetBytes(int64(archiveSize))
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			if err := compressTar(c, &buf, tree, level); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StopTimer()
+
+		b.ReportMetric(float64(archiveSize)/float64(buf.Len()), "ratio")
+	})
+}
+
+// BenchmarkTarDecompression measures extracting the compressed archive through each codec's reader and archive/tar
+func BenchmarkTarDecompression(b *testing.B) {
+	runTarMatrix(b, func(b *testing.B, c Codec, tree []treeFile, level int) {
+		archiveSize := len(tarArchive(b, tree))
+		var buf bytes.Buffer
+		if err := compressTar(c, &buf, tree, level); err != nil {
+			b.Fatal(err)
+		}
+		compressed := buf.Bytes()
+
+		b.ResetTimer()
+		b.SetBytes(int64(archiveSize))
+		for i := 0; i < b.N; i++ {
+			if err := decompressTar(c, compressed, discardEntry); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkTarParallelCompression compresses the tar stream as independent chunks on 1 to GOMAXPROCS goroutines
+func BenchmarkTarParallelCompression(b *testing.B) {
+	runTarMatrix(b, func(b *testing.B, c Codec, tree []treeFile, level int) {
+		for _, threads := range threadCounts() {
+			b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+				archiveSize := len(tarArchive(b, tree))
+				var buf bytes.Buffer
+				var chunks [][]byte
+
+				b.ResetTimer()
+				b.SetBytes(int64(archiveSize))
+				for i := 0; i < b.N; i++ {
+					buf.Reset()
+					if err := writeTar(&buf, tree); err != nil {
+						b.Fatal(err)
+					}
+					var err error
+					if chunks, err = compressChunks(c, buf.Bytes(), level, threads); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StopTimer()
+
+				compressedSize := 0
+				for _, chunk := range chunks {
+					compressedSize += len(chunk)
+				}
+				reportPerCore(b, archiveSize, threads)
+				b.ReportMetric(float64(archiveSize)/float64(compressedSize), "ratio")
+			})
+		}
+	})
+}
+
+// BenchmarkTarParallelDecompression decodes the chunks on 1 to GOMAXPROCS goroutines and extracts the tree
+func BenchmarkTarParallelDecompression(b *testing.B) {
+	runTarMatrix(b, func(b *testing.B, c Codec, tree []treeFile, level int) {
+		archive := tarArchive(b, tree)
+		chunks, err := compressChunks(c, archive, level, 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, threads := range threadCounts() {
+			b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+				b.SetBytes(int64(len(archive)))
+				for i := 0; i < b.N; i++ {
+					decoded, err := decompressChunks(c, chunks, threads)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if err := readTar(joinedReader(decoded), discardEntry); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StopTimer()
+
+				reportPerCore(b, len(archive), threads)
+			})
+		}
+	})
+}
+
+// TestTarPipeline checks that every codec round-trips the tree as one stream and as chunks
+func TestTarPipeline(t *testing.T) {
+	tree := generateTree(SmallSize, DefaultSeed)
+	archive := tarArchive(t, tree)
+	for _, c := range Codecs() {
+		level := c.Levels()[0]
+		t.Run(fmt.Sprintf("codec=%s/level=%d", c.Name(), level), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := compressTar(c, &buf, tree, level); err != nil {
+				t.Fatal(err)
+			}
+			checkTree(t, tree, func(fn func(string, []byte) error) error {
+				return decompressTar(c, buf.Bytes(), fn)
+			})
+
+			chunks, err := compressChunks(c, archive, level, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (len(archive) + tarChunkSize - 1) / tarChunkSize; len(chunks) != want {
+				t.Fatalf("got %d chunks, want %d", len(chunks), want)
+			}
+			decoded, err := decompressChunks(c, chunks, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTree(t, tree, func(fn func(string, []byte) error) error {
+				return readTar(joinedReader(decoded), fn)
+			})
+		})
+	}
+}
+
+// checkTree extracts an archive with extract and compares every entry with tree
+func checkTree(t *testing.T, tree []treeFile, extract func(fn func(string, []byte) error) error) {
+	t.Helper()
+	i := 0
+	err := extract(func(name string, data []byte) error {
+		if i >= len(tree) {
+			return fmt.Errorf("unexpected entry %s", name)
+		}
+		if name != tree[i].name || !bytes.Equal(data, tree[i].data) {
+			return fmt.Errorf("entry %d is %s with %d bytes, want %s with %d bytes", i, name, len(data), tree[i].name, len(tree[i].data))
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != len(tree) {
+		t.Fatalf("extracted %d entries, want %d", i, len(tree))
+	}
+}
+
+// TestGenerateTree checks that the tree is reproducible and mixes many small files with large ones
+func TestGenerateTree(t *testing.T) {
+	tree := generateTree(SmallSize, DefaultSeed)
+	if !bytes.Equal(tarArchive(t, tree), tarArchive(t, generateTree(SmallSize, DefaultSeed))) {
+		t.Fatal("tree is not reproducible")
+	}
+
+	var files, small, total int
+	var text, binary bool
+	for _, f := range tree {
+		if strings.HasSuffix(f.name, "/") {
+			continue
+		}
+		files++
+		total += len(f.data)
+		if len(f.data) < 4<<10 {
+			small++
+		}
+		text = text || strings.HasSuffix(f.name, ".txt")
+		binary = binary || strings.HasSuffix(f.name, ".bin")
+	}
+	if total < SmallSize {
+		t.Errorf("tree holds %d bytes, want at least %d", total, SmallSize)
+	}
+	if small < files/2 || !text || !binary {
+		t.Errorf("tree of %d files has %d under 4KB, text files %v, binary files %v", files, small, text, binary)
+	}
+}
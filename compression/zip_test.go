@@ -0,0 +1,140 @@
+package compression
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	kflate "github.com/klauspost/compress/flate"
+)
+
+// zipArchiveSize is the total uncompressed payload written to each zip archive
+const zipArchiveSize = SmallSize
+
+// zipFileSizes are the per-entry sizes used to split the payload into many small files
+var zipFileSizes = []int{1 << 10, 16 << 10}
+
+// zipStdlibLevel is the level of the compress/flate writer archive/zip uses by default
+const zipStdlibLevel = 5
+
+// zipCompressor sets up a zip.Writer's Deflate compressor for a level
+type zipCompressor struct {
+	name     string
+	levels   []int
+	register func(zw *zip.Writer, level int, pool *sync.Pool)
+}
+
+// zipCompressors are archive/zip's built-in compressor and registered klauspost writers
+var zipCompressors = []zipCompressor{
+	{"flate-stdlib", []int{zipStdlibLevel}, func(*zip.Writer, int, *sync.Pool) {}},
+	{"flate-klauspost", []int{1, 3, zipStdlibLevel, 9}, registerKlauspostZipCompressor},
+}
+
+// registerKlauspostZipCompressor registers klauspost flate writers reused through pool
+func registerKlauspostZipCompressor(zw *zip.Writer, level int, pool *sync.Pool) {
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		if fw, ok := pool.Get().(*kflate.Writer); ok {
+			fw.Reset(w)
+			return &pooledZipWriter{fw, pool}, nil
+		}
+		fw, err := kflate.NewWriter(w, level)
+		if err != nil {
+			return nil, err
+		}
+		return &pooledZipWriter{fw, pool}, nil
+	})
+}
+
+// pooledZipWriter returns its flate writer to the pool on Close
+type pooledZipWriter struct {
+	*kflate.Writer
+	pool *sync.Pool
+}
+
+func (w *pooledZipWriter) Close() error {
+	err := w.Writer.Close()
+	w.pool.Put(w.Writer)
+	return err
+}
+
+// BenchmarkZipArchive measures writing an archive of many small deflated files
+func BenchmarkZipArchive(b *testing.B) {
+	for _, c := range zipCompressors {
+		b.Run("compressor="+c.name, func(b *testing.B) {
+			for _, fileSize := range zipFileSizes {
+				b.Run("file="+sizeLabel(fileSize), func(b *testing.B) {
+					for _, dataType := range benchmarkDataTypes {
+						b.Run("data="+dataType, func(b *testing.B) {
+							for _, level := range c.levels {
+								b.Run(fmt.Sprintf("level=%d", level), func(b *testing.B) {
+									benchmarkZipArchive(b, c, fileSize, dataType, level)
+								})
+							}
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+// benchmarkZipArchive measures writing zipArchiveSize bytes split into fileSize entries through the compressor
+func benchmarkZipArchive(b *testing.B, c zipCompressor, fileSize int, dataType string, level int) {
+	data := testData(b, zipArchiveSize, dataType)
+
+	var buf bytes.Buffer
+	var pool sync.Pool
+	writeArchive := func() {
+		buf.Reset()
+		zw := zip.NewWriter(&buf)
+		c.register(zw, level, &pool)
+		for i := 0; i*fileSize < len(data); i++ {
+			f, err := zw.Create(fmt.Sprintf("file%05d.dat", i))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := f.Write(data[i*fileSize : min((i+1)*fileSize, len(data))]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	// Verify the archive reads back with the stdlib decompressor
+	writeArchive()
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var extracted []byte
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			b.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			b.Fatal(err)
+		}
+		rc.Close()
+		extracted = append(extracted, content...)
+	}
+	if !bytes.Equal(data, extracted) {
+		b.Fatal("Extracted archive does not match original")
+	}
+
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		writeArchive()
+	}
+	b.StopTimer()
+
+	// The ratio includes local headers and the central directory, which dominate for tiny files
+	b.ReportMetric(float64(len(data))/float64(buf.Len()), "ratio")
+}
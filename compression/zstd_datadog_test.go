@@ -0,0 +1,128 @@
+//go:build cgo
+
+package compression
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+
+	datadog "github.com/DataDog/zstd"
+)
+
+// DataDog needs cgo; zstd_nocgo_test.go records the codec as skipped otherwise
+func init() {
+	RegisterCodec(datadogZstdCodec{})
+	dictImplementations = append(dictImplementations, dictImplementation{datadogZstdName, newDatadogRecordCodec})
+	parallelWriters = append(parallelWriters, parallelWriter{datadogZstdName, newDatadogZstdParallelWriter})
+	zstdOptionEncoders = append(zstdOptionEncoders, zstdOptionEncoder{datadogZstdName, true, newDatadogZstdOptionEncoder})
+}
+
+// datadogZstdCodec adapts the cgo wrapper github.com/DataDog/zstd to the Codec interface
+type datadogZstdCodec struct{}
+
+func (datadogZstdCodec) Name() string  { return datadogZstdName }
+func (datadogZstdCodec) Levels() []int { return zstdLevels }
+
+// DataDog writes from the start of the buffer it is given, so it is handed the spare capacity of dst
+func (datadogZstdCodec) Compress(dst, src []byte, level int) ([]byte, error) {
+	if err := checkZstdLevel(datadogZstdName, level); err != nil {
+		return nil, err
+	}
+	dst = slices.Grow(dst, datadog.CompressBound(len(src)))
+	out, err := datadog.CompressLevel(dst[len(dst):cap(dst)], src, level)
+	if err != nil {
+		return nil, err
+	}
+	return dst[:len(dst)+len(out)], nil
+}
+
+func (datadogZstdCodec) Decompress(dst, src []byte) ([]byte, error) {
+	spare := dst[len(dst):cap(dst)]
+	out, err := datadog.Decompress(spare, src)
+	if err != nil {
+		return nil, err
+	}
+	// DataDog allocates a new buffer when the spare capacity is too small for the frame
+	if len(out) > 0 && len(spare) > 0 && &out[0] == &spare[0] {
+		return dst[:len(dst)+len(out)], nil
+	}
+	return append(dst, out...), nil
+}
+
+func (datadogZstdCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if err := checkZstdLevel(datadogZstdName, level); err != nil {
+		return nil, err
+	}
+	return datadog.NewWriterLevel(w, level), nil
+}
+
+func (datadogZstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return datadog.NewReader(r), nil
+}
+
+func newDatadogRecordCodec(level int, d []byte) (recordCodec, error) {
+	if d == nil {
+		return recordCodec{
+			compress: func(dst, src []byte) ([]byte, error) {
+				return datadog.CompressLevel(dst, src, level)
+			},
+			decompress: datadog.Decompress,
+			close:      func() {},
+		}, nil
+	}
+
+	p, err := datadog.NewBulkProcessor(d, level)
+	if err != nil {
+		return recordCodec{}, err
+	}
+	return recordCodec{
+		compress:   p.Compress,
+		decompress: p.Decompress,
+		close:      func() {},
+	}, nil
+}
+
+// newDatadogZstdParallelWriter maps one thread to libzstd's synchronous mode and n threads to n workers
+func newDatadogZstdParallelWriter(w io.Writer, threads int) (io.WriteCloser, error) {
+	zw := datadog.NewWriterLevel(w, parallelZstdLevel)
+	workers := threads
+	if threads == 1 {
+		workers = 0
+	}
+	if err := zw.SetNbWorkers(workers); err != nil {
+		return nil, err
+	}
+	return zw, nil
+}
+
+func newDatadogZstdOptionEncoder(p zstdParams) (func(dst, src []byte) ([]byte, error), func() error, error) {
+	enc, err := newDatadogParamsEncoder(p.level, p.window, p.ldm, p.checksum)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc.Compress, enc.Close, nil
+}
+
+// TestDatadogZstdMultiFrame checks that DataDog decodes streams of several frames
+func TestDatadogZstdMultiFrame(t *testing.T) {
+	data := testData(t, SmallSize, TextData)
+	compressed := compressSeekable(t, data, 64<<10)
+
+	readers := map[string]func() ([]byte, error){
+		apiOneShot: func() ([]byte, error) { return datadogZstdCodec{}.Decompress(nil, compressed) },
+		apiStream:  func() ([]byte, error) { return io.ReadAll(datadog.NewReader(bytes.NewReader(compressed))) },
+	}
+	for api, read := range readers {
+		t.Run("api="+api, func(t *testing.T) {
+			got, err := read()
+			if err != nil {
+				t.Skipf("known issue: multi-frame streams fail with %v, %s", err, datadogZstdIssues)
+			}
+			if !bytes.Equal(data, got) {
+				t.Error("decompressed data does not match original")
+			}
+		})
+	}
+}
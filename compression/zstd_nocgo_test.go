@@ -0,0 +1,8 @@
+//go:build !cgo
+
+package compression
+
+// github.com/DataDog/zstd needs cgo, so builds without it report DataDog as skipped
+func init() {
+	SkipCodec(datadogZstdName, "github.com/DataDog/zstd requires cgo; build with CGO_ENABLED=1 to include it")
+}
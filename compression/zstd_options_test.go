@@ -0,0 +1,252 @@
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	klauspost "github.com/klauspost/compress/zstd"
+)
+
+// zstdOptionLevel is the level every option combination runs at, the default of both implementations
+const zstdOptionLevel = 3
+
+// zstdOptionWindows are the window sizes swept; 0 keeps the level's default
+var zstdOptionWindows = []int{0, 1 << 20, 8 << 20, 32 << 20, 128 << 20}
+
+// zstdParams are the frame parameters benchmarked beyond the level
+type zstdParams struct {
+	level    int
+	window   int  // window size in bytes, a power of two; 0 keeps the level's default
+	ldm      bool // long-distance matching
+	checksum bool // XXH64 content checksum in the frame
+}
+
+// zstdOptionEncoder creates a one-shot zstd compressor for a set of frame parameters
+type zstdOptionEncoder struct {
+	name string
+	ldm  bool // whether the implementation supports long-distance matching
+	new  func(p zstdParams) (compress func(dst, src []byte) ([]byte, error), close func() error, err error)
+}
+
+// klauspost has no long-distance matching mode, so only DataDog, added in cgo builds, is swept with ldm=on
+var zstdOptionEncoders = []zstdOptionEncoder{
+	{klauspostZstd.Name(), false, newKlauspostZstdOptionEncoder},
+}
+
+func newKlauspostZstdOptionEncoder(p zstdParams) (func(dst, src []byte) ([]byte, error), func() error, error) {
+	opts := []klauspost.EOption{
+		klauspost.WithEncoderLevel(klauspost.EncoderLevelFromZstd(p.level)),
+		klauspost.WithEncoderCRC(p.checksum),
+		klauspost.WithEncoderConcurrency(1),
+	}
+	if p.window > 0 {
+		opts = append(opts, klauspost.WithWindowSize(p.window))
+	}
+	enc, err := klauspost.NewWriter(nil, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	compress := func(dst, src []byte) ([]byte, error) {
+		return enc.EncodeAll(src, dst), nil
+	}
+	return compress, enc.Close, nil
+}
+
+// zstdOptionDecompress decodes with the implementation that encoded
+func zstdOptionDecompress(name string, src []byte) ([]byte, error) {
+	c, ok := LookupCodec(name)
+	if !ok {
+		return nil, fmt.Errorf("codec %q is not registered", name)
+	}
+	return c.Decompress(nil, src)
+}
+
+// zstdOptionParams lists the parameter combinations benchmarked for an encoder
+func zstdOptionParams(e zstdOptionEncoder) []zstdParams {
+	ldm := []bool{false}
+	if e.ldm {
+		ldm = append(ldm, true)
+	}
+	var params []zstdParams
+	for _, window := range zstdOptionWindows {
+		for _, l := range ldm {
+			for _, checksum := range []bool{true, false} {
+				params = append(params, zstdParams{level: zstdOptionLevel, window: window, ldm: l, checksum: checksum})
+			}
+		}
+	}
+	return params
+}
+
+// zstdOptionName formats the option part of a sub-benchmark name, e.g. window=8MB/ldm=off/crc=on
+func zstdOptionName(p zstdParams) string {
+	window := "default"
+	if p.window > 0 {
+		window = sizeLabel(p.window)
+	}
+	return fmt.Sprintf("window=%s/ldm=%s/crc=%s", window, onOff(p.ldm), onOff(p.checksum))
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// BenchmarkZstdOptionsCompression measures the speed and ratio of window size, LDM and checksum settings
+func BenchmarkZstdOptionsCompression(b *testing.B) {
+	runZstdOptionMatrix(b, benchmarkZstdOptionCompress)
+}
+
+// BenchmarkZstdOptionsDecompression measures decompression speed of the same frames
+func BenchmarkZstdOptionsDecompression(b *testing.B) {
+	runZstdOptionMatrix(b, benchmarkZstdOptionDecompress)
+}
+
+// runZstdOptionMatrix runs fn for every encoder, data type and parameter combination
+func runZstdOptionMatrix(b *testing.B, fn func(b *testing.B, e zstdOptionEncoder, data []byte, p zstdParams)) {
+	for _, e := range zstdOptionEncoders {
+		b.Run("codec="+e.name, func(b *testing.B) {
+			b.Run("size="+sizeLabel(LargeSize), func(b *testing.B) {
+				for _, dataType := range benchmarkDataTypes {
+					b.Run("data="+dataType, func(b *testing.B) {
+						data := testData(b, LargeSize, dataType)
+						for _, p := range zstdOptionParams(e) {
+							b.Run(zstdOptionName(p), func(b *testing.B) {
+								fn(b, e, data, p)
+							})
+						}
+					})
+				}
+			})
+		})
+	}
+}
+
+func benchmarkZstdOptionCompress(b *testing.B, e zstdOptionEncoder, data []byte, p zstdParams) {
+	compress, closeEncoder, err := e.new(p)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer closeEncoder()
+
+	var compressed []byte
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		compressed, err = compress(compressed[:0], data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(len(data))/float64(len(compressed)), "ratio")
+}
+
+func benchmarkZstdOptionDecompress(b *testing.B, e zstdOptionEncoder, data []byte, p zstdParams) {
+	compress, closeEncoder, err := e.new(p)
+	if err != nil {
+		b.Fatal(err)
+	}
+	compressed, err := compress(nil, data)
+	closeEncoder()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// Verify decompression
+	decompressed, err := zstdOptionDecompress(e.name, compressed)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if !bytes.Equal(data, decompressed) {
+		b.Fatal("Decompressed data does not match original")
+	}
+
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := zstdOptionDecompress(e.name, compressed); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Bits of the frame header descriptor
+const (
+	zstdChecksumFlag      = 1 << 2
+	zstdSingleSegmentFlag = 1 << 5
+)
+
+// zstdFrameWindow returns the window size a frame header declares
+func zstdFrameWindow(frame []byte) (int, error) {
+	if len(frame) < 6 {
+		return 0, fmt.Errorf("frame header truncated at %d bytes", len(frame))
+	}
+	fhd := frame[4]
+	if fhd&zstdSingleSegmentFlag == 0 {
+		exponent, mantissa := int(frame[5]>>3), int(frame[5]&7)
+		base := 1 << (10 + exponent)
+		return base + base/8*mantissa, nil
+	}
+	off := 5 + []int{0, 1, 2, 4}[fhd&3]
+	n := []int{1, 2, 4, 8}[fhd>>6]
+	if len(frame) < off+n {
+		return 0, fmt.Errorf("frame header truncated at %d bytes", len(frame))
+	}
+	var size uint64
+	for i := n - 1; i >= 0; i-- {
+		size = size<<8 | uint64(frame[off+i])
+	}
+	if n == 2 {
+		size += 256
+	}
+	return int(size), nil
+}
+
+// TestZstdOptions checks that every option combination decodes and sets the frame's window and checksum
+func TestZstdOptions(t *testing.T) {
+	data := testData(t, MediumSize, TextData)
+	for _, e := range zstdOptionEncoders {
+		for _, p := range zstdOptionParams(e) {
+			t.Run(fmt.Sprintf("codec=%s/%s", e.name, zstdOptionName(p)), func(t *testing.T) {
+				compress, closeEncoder, err := e.new(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer closeEncoder()
+				compressed, err := compress(nil, data)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got := compressed[4]&zstdChecksumFlag != 0; got != p.checksum {
+					t.Errorf("frame checksum flag is %v, want %v", got, p.checksum)
+				}
+				window, err := zstdFrameWindow(compressed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Windows at least as large as the input shrink to it
+				switch {
+				case p.window > 0 && p.window < len(data) && window != p.window:
+					t.Errorf("frame window is %d, want %d", window, p.window)
+				case p.window >= len(data) && window < len(data):
+					t.Errorf("frame window is %d, want at least the %d byte input", window, len(data))
+				}
+				for _, decoder := range zstdOptionEncoders {
+					got, err := zstdOptionDecompress(decoder.name, compressed)
+					if err != nil {
+						t.Fatalf("%s: %v", decoder.name, err)
+					}
+					if !bytes.Equal(data, got) {
+						t.Fatalf("%s: decompressed data does not match original", decoder.name)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,103 @@
+//go:build cgo
+
+package compression
+
+/*
+#include <stddef.h>
+
+// Declarations from zstd.h for the libzstd that github.com/DataDog/zstd compiles in
+typedef struct ZSTD_CCtx_s ZSTD_CCtx;
+
+ZSTD_CCtx* ZSTD_createCCtx(void);
+size_t ZSTD_freeCCtx(ZSTD_CCtx* cctx);
+size_t ZSTD_CCtx_setParameter(ZSTD_CCtx* cctx, int param, int value);
+size_t ZSTD_compress2(ZSTD_CCtx* cctx, void* dst, size_t dstCapacity, const void* src, size_t srcSize);
+size_t ZSTD_compressBound(size_t srcSize);
+unsigned ZSTD_isError(size_t code);
+const char* ZSTD_getErrorName(size_t code);
+
+enum {
+	zstdCompressionLevel = 100, // ZSTD_c_compressionLevel
+	zstdWindowLog        = 101, // ZSTD_c_windowLog
+	zstdLongDistance     = 160, // ZSTD_c_enableLongDistanceMatching
+	zstdChecksumFlag     = 201, // ZSTD_c_checksumFlag
+};
+*/
+import "C"
+
+import (
+	"errors"
+	"math/bits"
+	"slices"
+	"unsafe"
+
+	_ "github.com/DataDog/zstd" // links the libzstd declared above
+)
+
+// datadogParamsEncoder sets libzstd parameters DataDog's Go API does not expose; it is not safe for concurrent use
+type datadogParamsEncoder struct {
+	cctx *C.ZSTD_CCtx
+}
+
+// newDatadogParamsEncoder returns an encoder that must be closed; a window of 0 keeps the level's default
+func newDatadogParamsEncoder(level, window int, ldm, checksum bool) (*datadogParamsEncoder, error) {
+	cctx := C.ZSTD_createCCtx()
+	if cctx == nil {
+		return nil, errors.New("zstd-datadog: cannot allocate compression context")
+	}
+	e := &datadogParamsEncoder{cctx: cctx}
+
+	params := [][2]C.int{
+		{C.zstdCompressionLevel, C.int(level)},
+		{C.zstdLongDistance, boolParam(ldm)},
+		{C.zstdChecksumFlag, boolParam(checksum)},
+	}
+	if window > 0 {
+		params = append(params, [2]C.int{C.zstdWindowLog, C.int(bits.Len(uint(window)) - 1)})
+	}
+	for _, param := range params {
+		if err := zstdError(C.ZSTD_CCtx_setParameter(cctx, param[0], param[1])); err != nil {
+			e.Close()
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Compress appends a frame holding src to dst
+func (e *datadogParamsEncoder) Compress(dst, src []byte) ([]byte, error) {
+	bound := int(C.ZSTD_compressBound(C.size_t(len(src))))
+	start := len(dst)
+	dst = slices.Grow(dst, bound)[:start+bound]
+	var srcPtr unsafe.Pointer
+	if len(src) > 0 {
+		srcPtr = unsafe.Pointer(&src[0])
+	}
+	n := C.ZSTD_compress2(e.cctx, unsafe.Pointer(&dst[start]), C.size_t(bound), srcPtr, C.size_t(len(src)))
+	if err := zstdError(n); err != nil {
+		return nil, err
+	}
+	return dst[:start+int(n)], nil
+}
+
+// Close frees the compression context
+func (e *datadogParamsEncoder) Close() error {
+	C.ZSTD_freeCCtx(e.cctx)
+	return nil
+}
+
+// boolParam converts a flag to libzstd's 0/1 parameter values
+func boolParam(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// zstdError converts a libzstd return code to an error
+func zstdError(code C.size_t) error {
+	if C.ZSTD_isError(code) == 0 {
+		return nil
+	}
+	return errors.New("zstd-datadog: " + C.GoString(C.ZSTD_getErrorName(code)))
+}
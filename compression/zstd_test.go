@@ -1,340 +1,164 @@
 package compression
 
 import (
-	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
 	"testing"
 
-	datadog "github.com/DataDog/zstd"
 	klauspost "github.com/klauspost/compress/zstd"
 )
 
-// Benchmark Klauspost zstd at different levels
-func BenchmarkKlauspostZstdCompress1MB_Level1(b *testing.B) {
-	benchmarkKlauspostZstdCompress(b, SmallSize, TextData, 1)
-}
-
-func BenchmarkKlauspostZstdCompress1MB_Level3(b *testing.B) {
-	benchmarkKlauspostZstdCompress(b, SmallSize, TextData, 3)
-}
-
-func BenchmarkKlauspostZstdCompress10MB_Level1(b *testing.B) {
-	benchmarkKlauspostZstdCompress(b, MediumSize, TextData, 1)
-}
-
-func BenchmarkKlauspostZstdCompress10MB_Level3(b *testing.B) {
-	benchmarkKlauspostZstdCompress(b, MediumSize, TextData, 3)
-}
-
-func BenchmarkKlauspostZstdCompressBinary1MB_Level1(b *testing.B) {
-	benchmarkKlauspostZstdCompress(b, SmallSize, BinaryData, 1)
-}
-
-func BenchmarkKlauspostZstdCompressBinary1MB_Level3(b *testing.B) {
-	benchmarkKlauspostZstdCompress(b, SmallSize, BinaryData, 3)
-}
-
-func BenchmarkKlauspostZstdCompressBinary10MB_Level1(b *testing.B) {
-	benchmarkKlauspostZstdCompress(b, MediumSize, BinaryData, 1)
-}
-
-func BenchmarkKlauspostZstdCompressBinary10MB_Level3(b *testing.B) {
-	benchmarkKlauspostZstdCompress(b, MediumSize, BinaryData, 3)
-}
-
-// Benchmark Klauspost zstd decompression
-func BenchmarkKlauspostZstdDecompress1MB_Level1(b *testing.B) {
-	benchmarkKlauspostZstdDecompress(b, SmallSize, TextData, 1)
-}
-
-func BenchmarkKlauspostZstdDecompress1MB_Level3(b *testing.B) {
-	benchmarkKlauspostZstdDecompress(b, SmallSize, TextData, 3)
-}
-
-func BenchmarkKlauspostZstdDecompress10MB_Level1(b *testing.B) {
-	benchmarkKlauspostZstdDecompress(b, MediumSize, TextData, 1)
-}
-
-func BenchmarkKlauspostZstdDecompress10MB_Level3(b *testing.B) {
-	benchmarkKlauspostZstdDecompress(b, MediumSize, TextData, 3)
-}
-
-func BenchmarkKlauspostZstdDecompressBinary1MB_Level1(b *testing.B) {
-	benchmarkKlauspostZstdDecompress(b, SmallSize, BinaryData, 1)
-}
-
-func BenchmarkKlauspostZstdDecompressBinary1MB_Level3(b *testing.B) {
-	benchmarkKlauspostZstdDecompress(b, SmallSize, BinaryData, 3)
-}
-
-func BenchmarkKlauspostZstdDecompressBinary10MB_Level1(b *testing.B) {
-	benchmarkKlauspostZstdDecompress(b, MediumSize, BinaryData, 1)
-}
-
-func BenchmarkKlauspostZstdDecompressBinary10MB_Level3(b *testing.B) {
-	benchmarkKlauspostZstdDecompress(b, MediumSize, BinaryData, 3)
-}
-
-// Benchmark DataDog zstd at different levels
-func BenchmarkDataDogZstdCompress1MB_Level1(b *testing.B) {
-	benchmarkDataDogZstdCompress(b, SmallSize, TextData, 1)
-}
-
-func BenchmarkDataDogZstdCompress1MB_Level3(b *testing.B) {
-	benchmarkDataDogZstdCompress(b, SmallSize, TextData, 3)
-}
-
-func BenchmarkDataDogZstdCompress10MB_Level1(b *testing.B) {
-	benchmarkDataDogZstdCompress(b, MediumSize, TextData, 1)
-}
-
-func BenchmarkDataDogZstdCompress10MB_Level3(b *testing.B) {
-	benchmarkDataDogZstdCompress(b, MediumSize, TextData, 3)
-}
-
-func BenchmarkDataDogZstdCompressBinary1MB_Level1(b *testing.B) {
-	benchmarkDataDogZstdCompress(b, SmallSize, BinaryData, 1)
-}
-
-func BenchmarkDataDogZstdCompressBinary1MB_Level3(b *testing.B) {
-	benchmarkDataDogZstdCompress(b, SmallSize, BinaryData, 3)
-}
-
-func BenchmarkDataDogZstdCompressBinary10MB_Level1(b *testing.B) {
-	benchmarkDataDogZstdCompress(b, MediumSize, BinaryData, 1)
-}
-
-func BenchmarkDataDogZstdCompressBinary10MB_Level3(b *testing.B) {
-	benchmarkDataDogZstdCompress(b, MediumSize, BinaryData, 3)
-}
+// zstdLevels are the representative levels of each klauspost encoder tier
+var zstdLevels = []int{1, 3, 7, 11}
 
-// Benchmark DataDog zstd decompression
-func BenchmarkDataDogZstdDecompress1MB_Level1(b *testing.B) {
-	benchmarkDataDogZstdDecompress(b, SmallSize, TextData, 1)
-}
+// klauspostZstd is shared so its cached encoders survive across benchmarks
+var klauspostZstd = &klauspostZstdCodec{}
 
-func BenchmarkDataDogZstdDecompress1MB_Level3(b *testing.B) {
-	benchmarkDataDogZstdDecompress(b, SmallSize, TextData, 3)
-}
+// datadogZstdName names the DataDog zstd codec, which is only registered in cgo builds
+const datadogZstdName = "zstd-datadog"
 
-func BenchmarkDataDogZstdDecompress10MB_Level1(b *testing.B) {
-	benchmarkDataDogZstdDecompress(b, MediumSize, TextData, 1)
-}
+// datadogZstdIssues is the upstream tracker for the DataDog zstd issues the tests skip
+const datadogZstdIssues = "https://github.com/DataDog/zstd/issues"
 
-func BenchmarkDataDogZstdDecompress10MB_Level3(b *testing.B) {
-	benchmarkDataDogZstdDecompress(b, MediumSize, TextData, 3)
+func init() {
+	RegisterCodec(klauspostZstd)
 }
 
-func BenchmarkDataDogZstdDecompressBinary1MB_Level1(b *testing.B) {
-	benchmarkDataDogZstdDecompress(b, SmallSize, BinaryData, 1)
-}
-
-func BenchmarkDataDogZstdDecompressBinary1MB_Level3(b *testing.B) {
-	benchmarkDataDogZstdDecompress(b, SmallSize, BinaryData, 3)
+// klauspostZstdLevel maps a level in zstdLevels to the klauspost EncoderLevel EncoderLevelFromZstd selects
+func klauspostZstdLevel(level int) (klauspost.EncoderLevel, error) {
+	if err := checkZstdLevel("zstd-klauspost", level); err != nil {
+		return 0, err
+	}
+	return klauspost.EncoderLevelFromZstd(level), nil
 }
 
-func BenchmarkDataDogZstdDecompressBinary10MB_Level1(b *testing.B) {
-	benchmarkDataDogZstdDecompress(b, MediumSize, BinaryData, 1)
+// checkZstdLevel rejects levels outside zstdLevels
+func checkZstdLevel(name string, level int) error {
+	if !slices.Contains(zstdLevels, level) {
+		return fmt.Errorf("%s: unsupported level %d (want one of %v)", name, level, zstdLevels)
+	}
+	return nil
 }
 
-func BenchmarkDataDogZstdDecompressBinary10MB_Level3(b *testing.B) {
-	benchmarkDataDogZstdDecompress(b, MediumSize, BinaryData, 3)
+// klauspostZstdCodec adapts github.com/klauspost/compress/zstd, reusing one encoder per level and one decoder
+type klauspostZstdCodec struct {
+	mu       sync.Mutex
+	encoders map[klauspost.EncoderLevel]*klauspost.Encoder
+	decoder  *klauspost.Decoder
 }
 
-// Helper function for benchmarking Klauspost compression
-func benchmarkKlauspostZstdCompress(b *testing.B, size int, dataType string, level int) {
-	data := GenerateTestData(size, dataType)
-
-	// Map integer levels to klauspost EncoderLevel based on Klauspost's own documentation:
-	// SpeedFastest: roughly equivalent to zstd level 1
-	// SpeedDefault: roughly equivalent to zstd level 3
-	var encoderLevel klauspost.EncoderLevel
-	switch level {
-	case 1:
-		encoderLevel = klauspost.SpeedFastest // "roughly equivalent to the fastest Zstandard mode"
-	case 3:
-		encoderLevel = klauspost.SpeedDefault // "roughly equivalent to the default Zstandard mode (level 3)"
-	default:
-		encoderLevel = klauspost.SpeedDefault
-	}
+func (*klauspostZstdCodec) Name() string  { return "zstd-klauspost" }
+func (*klauspostZstdCodec) Levels() []int { return zstdLevels }
 
-	enc, err := klauspost.NewWriter(nil, klauspost.WithEncoderLevel(encoderLevel))
+// DescribeLevel reports which klauspost encoder tier a numeric level runs as
+func (*klauspostZstdCodec) DescribeLevel(level int) string {
+	encoderLevel, err := klauspostZstdLevel(level)
 	if err != nil {
-		b.Fatal(err)
-	}
-
-	b.ResetTimer()
-	b.SetBytes(int64(size))
-	for i := 0; i < b.N; i++ {
-		_ = enc.EncodeAll(data, nil)
+		return err.Error()
 	}
+	return encoderLevel.String()
 }
 
-// Helper function for benchmarking Klauspost decompression
-func benchmarkKlauspostZstdDecompress(b *testing.B, size int, dataType string, level int) {
-	data := GenerateTestData(size, dataType)
-
-	// Map integer levels to klauspost EncoderLevel based on Klauspost's own documentation:
-	// SpeedFastest: roughly equivalent to zstd level 1
-	// SpeedDefault: roughly equivalent to zstd level 3
-	var encoderLevel klauspost.EncoderLevel
-	switch level {
-	case 1:
-		encoderLevel = klauspost.SpeedFastest // "roughly equivalent to the fastest Zstandard mode"
-	case 3:
-		encoderLevel = klauspost.SpeedDefault // "roughly equivalent to the default Zstandard mode (level 3)"
-	default:
-		encoderLevel = klauspost.SpeedDefault
+func (c *klauspostZstdCodec) Compress(dst, src []byte, level int) ([]byte, error) {
+	enc, err := c.encoder(level)
+	if err != nil {
+		return nil, err
 	}
+	return enc.EncodeAll(src, dst), nil
+}
 
-	enc, err := klauspost.NewWriter(nil, klauspost.WithEncoderLevel(encoderLevel))
-	if err != nil {
-		b.Fatal(err)
+func (c *klauspostZstdCodec) Decompress(dst, src []byte) ([]byte, error) {
+	c.mu.Lock()
+	if c.decoder == nil {
+		dec, err := klauspost.NewReader(nil)
+		if err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+		c.decoder = dec
 	}
+	dec := c.decoder
+	c.mu.Unlock()
 
-	compressed := enc.EncodeAll(data, nil)
+	return dec.DecodeAll(src, dst)
+}
 
-	dec, err := klauspost.NewReader(nil)
+func (*klauspostZstdCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	encoderLevel, err := klauspostZstdLevel(level)
 	if err != nil {
-		b.Fatal(err)
+		return nil, err
 	}
-
-	// Verify decompression
-	decompressed, err := dec.DecodeAll(compressed, nil)
+	enc, err := klauspost.NewWriter(w, klauspost.WithEncoderLevel(encoderLevel), klauspost.WithZeroFrames(true))
 	if err != nil {
-		b.Fatal(err)
-	}
-	if !bytes.Equal(data, decompressed) {
-		b.Fatal("Decompressed data does not match original")
+		return nil, err
 	}
+	return enc, nil
+}
 
-	b.ResetTimer()
-	b.SetBytes(int64(size))
-	for i := 0; i < b.N; i++ {
-		_, err := dec.DecodeAll(compressed, nil)
-		if err != nil {
-			b.Fatal(err)
-		}
+func (*klauspostZstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	dec, err := klauspost.NewReader(r)
+	if err != nil {
+		return nil, err
 	}
+	return klauspostZstdReader{dec}, nil
 }
 
-// Helper function for benchmarking DataDog compression
-func benchmarkDataDogZstdCompress(b *testing.B, size int, dataType string, level int) {
-	data := GenerateTestData(size, dataType)
-
-	b.ResetTimer()
-	b.SetBytes(int64(size))
-	for i := 0; i < b.N; i++ {
-		_, _ = datadog.CompressLevel(nil, data, level)
-	}
+func (*klauspostZstdCodec) ResetWriter(zw io.WriteCloser, w io.Writer) error {
+	return resetWriter(zw, w)
 }
 
-// Helper function for benchmarking DataDog decompression
-func benchmarkDataDogZstdDecompress(b *testing.B, size int, dataType string, level int) {
-	data := GenerateTestData(size, dataType)
-	compressed, _ := datadog.CompressLevel(nil, data, level)
+func (*klauspostZstdCodec) ResetReader(zr io.ReadCloser, r io.Reader) error {
+	return resetReader(zr, r)
+}
 
-	// Verify decompression
-	decompressed, err := datadog.Decompress(nil, compressed)
+// encoder returns the shared encoder for a level, creating it on first use
+func (c *klauspostZstdCodec) encoder(level int) (*klauspost.Encoder, error) {
+	encoderLevel, err := klauspostZstdLevel(level)
 	if err != nil {
-		b.Fatal(err)
-	}
-	if !bytes.Equal(data, decompressed) {
-		b.Fatal("Decompressed data does not match original")
+		return nil, err
 	}
 
-	b.ResetTimer()
-	b.SetBytes(int64(size))
-	for i := 0; i < b.N; i++ {
-		_, err := datadog.Decompress(nil, compressed)
-		if err != nil {
-			b.Fatal(err)
-		}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if enc, ok := c.encoders[encoderLevel]; ok {
+		return enc, nil
 	}
+	enc, err := klauspost.NewWriter(nil, klauspost.WithEncoderLevel(encoderLevel), klauspost.WithZeroFrames(true))
+	if err != nil {
+		return nil, err
+	}
+	if c.encoders == nil {
+		c.encoders = make(map[klauspost.EncoderLevel]*klauspost.Encoder)
+	}
+	c.encoders[encoderLevel] = enc
+	return enc, nil
 }
 
-// BenchmarkZstdCompressionRatio measures and reports compression ratios
-func BenchmarkZstdCompressionRatio(b *testing.B) {
-	// This doesn't actually benchmark speed but uses the benchmark framework to report metrics
-	b.Run("Klauspost-Zstd-1MB-Level1", func(b *testing.B) {
-		measureZstdCompressionRatio(b, SmallSize, TextData, 1, "klauspost")
-	})
-	b.Run("Klauspost-Zstd-1MB-Level3", func(b *testing.B) {
-		measureZstdCompressionRatio(b, SmallSize, TextData, 3, "klauspost")
-	})
-	b.Run("Klauspost-Zstd-10MB-Level1", func(b *testing.B) {
-		measureZstdCompressionRatio(b, MediumSize, TextData, 1, "klauspost")
-	})
-	b.Run("Klauspost-Zstd-10MB-Level3", func(b *testing.B) {
-		measureZstdCompressionRatio(b, MediumSize, TextData, 3, "klauspost")
-	})
-
-	b.Run("DataDog-Zstd-1MB-Level1", func(b *testing.B) {
-		measureZstdCompressionRatio(b, SmallSize, TextData, 1, "datadog")
-	})
-	b.Run("DataDog-Zstd-1MB-Level3", func(b *testing.B) {
-		measureZstdCompressionRatio(b, SmallSize, TextData, 3, "datadog")
-	})
-	b.Run("DataDog-Zstd-10MB-Level1", func(b *testing.B) {
-		measureZstdCompressionRatio(b, MediumSize, TextData, 1, "datadog")
-	})
-	b.Run("DataDog-Zstd-10MB-Level3", func(b *testing.B) {
-		measureZstdCompressionRatio(b, MediumSize, TextData, 3, "datadog")
-	})
-
-	// Binary data
-	b.Run("Klauspost-Zstd-Binary-1MB-Level1", func(b *testing.B) {
-		measureZstdCompressionRatio(b, SmallSize, BinaryData, 1, "klauspost")
-	})
-	b.Run("Klauspost-Zstd-Binary-1MB-Level3", func(b *testing.B) {
-		measureZstdCompressionRatio(b, SmallSize, BinaryData, 3, "klauspost")
-	})
-	b.Run("DataDog-Zstd-Binary-1MB-Level1", func(b *testing.B) {
-		measureZstdCompressionRatio(b, SmallSize, BinaryData, 1, "datadog")
-	})
-	b.Run("DataDog-Zstd-Binary-1MB-Level3", func(b *testing.B) {
-		measureZstdCompressionRatio(b, SmallSize, BinaryData, 3, "datadog")
-	})
-
-	// Random data (should compress poorly)
-	b.Run("Klauspost-Zstd-Random-1MB-Level3", func(b *testing.B) {
-		measureZstdCompressionRatio(b, SmallSize, RandomData, 3, "klauspost")
-	})
-	b.Run("DataDog-Zstd-Random-1MB-Level3", func(b *testing.B) {
-		measureZstdCompressionRatio(b, SmallSize, RandomData, 3, "datadog")
-	})
+// klauspostZstdReader exposes a Decoder as an io.ReadCloser that keeps its Reset method
+type klauspostZstdReader struct {
+	*klauspost.Decoder
 }
 
-func measureZstdCompressionRatio(b *testing.B, size int, dataType string, level int, implementation string) {
-	b.Helper()
-	data := GenerateTestData(size, dataType)
-	var compressed []byte
+func (r klauspostZstdReader) Close() error {
+	r.Decoder.Close()
+	return nil
+}
 
-	if implementation == "klauspost" {
-		// Map integer levels to klauspost EncoderLevel based on Klauspost's own documentation
-		var encoderLevel klauspost.EncoderLevel
-		switch level {
-		case 1:
-			encoderLevel = klauspost.SpeedFastest
-		case 3:
-			encoderLevel = klauspost.SpeedDefault
-		default:
-			encoderLevel = klauspost.SpeedDefault
+// TestZstdUnsupportedLevel checks that every zstd codec rejects a level outside zstdLevels
+func TestZstdUnsupportedLevel(t *testing.T) {
+	const level = 2
+	for _, name := range []string{klauspostZstd.Name(), datadogZstdName} {
+		c, ok := LookupCodec(name)
+		if !ok {
+			continue
 		}
-
-		enc, err := klauspost.NewWriter(nil, klauspost.WithEncoderLevel(encoderLevel))
-		if err != nil {
-			b.Fatal(err)
+		if _, err := c.Compress(nil, []byte("data"), level); err == nil {
+			t.Errorf("%s: Compress accepted level %d", name, level)
 		}
-		compressed = enc.EncodeAll(data, nil)
-	} else {
-		var err error
-		compressed, err = datadog.CompressLevel(nil, data, level)
-		if err != nil {
-			b.Fatal(err)
+		if _, err := c.NewWriter(io.Discard, level); err == nil {
+			t.Errorf("%s: NewWriter accepted level %d", name, level)
 		}
 	}
-
-	ratio := float64(len(data)) / float64(len(compressed))
-	b.ReportMetric(ratio, "ratio")
-	b.ReportMetric(0, "ns/op") // This benchmark doesn't measure time
 }
@@ -8,6 +8,7 @@ require (
 	github.com/brianvoe/gofakeit/v7 v7.5.1
 	github.com/bytedance/sonic v1.15.0
 	github.com/goccy/go-yaml v1.19.2
+	github.com/golang/snappy v1.0.0
 	github.com/klauspost/compress v1.18.3
 	gopkg.in/yaml.v2 v2.4.0
 	gopkg.in/yaml.v3 v3.0.1